@@ -1,6 +1,7 @@
 package main
 
 import (
+	_ "embed"
 	"fmt"
 	"log"
 	"os"
@@ -8,27 +9,181 @@ import (
 	"github.com/crbroughton/pkg-exploration/pkg/cmd"
 )
 
+//go:embed config.example.toml
+var exampleConfig []byte
+
+//go:embed manifest.example.toml
+var exampleManifest []byte
+
 func main() {
 	if len(os.Args) < 2 {
 		printUsage()
 		os.Exit(1)
 	}
 
+	os.Args = takeNoColor(os.Args)
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
 	command := os.Args[1]
+	cmd.RecordFailures(os.Args)
 
 	switch command {
+	case "init":
+		cmd.Init(os.Args[2:], cmd.Examples{Config: exampleConfig, Manifest: exampleManifest})
+	case "new":
+		cmd.New(os.Args[2:])
 	case "switch":
 		cmd.Switch(os.Args[2:])
+	case "plan":
+		cmd.Plan(os.Args[2:])
+	case "apply":
+		cmd.Apply(os.Args[2:])
+	case "fetch":
+		cmd.Fetch(os.Args[2:])
+	case "gc":
+		cmd.GC(os.Args[2:])
+	case "upgrade":
+		cmd.Upgrade(os.Args[2:])
+	case "downgrade":
+		cmd.Downgrade(os.Args[2:])
+	case "generations":
+		cmd.Generations(os.Args[2:])
+	case "use":
+		cmd.Use(os.Args[2:])
+	case "snapshot":
+		cmd.Snapshot(os.Args[2:])
+	case "report":
+		cmd.Report(os.Args[2:])
+	case "undo":
+		cmd.Undo(os.Args[2:])
+	case "restore":
+		cmd.Restore(os.Args[2:])
+	case "restore-backups":
+		cmd.RestoreBackups(os.Args[2:])
+	case "schedule":
+		cmd.Schedule(os.Args[2:])
+	case "outdated":
+		cmd.Outdated(os.Args[2:])
+	case "status":
+		cmd.Status(os.Args[2:])
+	case "repair":
+		cmd.Repair(os.Args[2:])
+	case "compare":
+		cmd.Compare(os.Args[2:])
+	case "assert":
+		cmd.Assert(os.Args[2:])
+	case "list":
+		cmd.List(os.Args[2:])
+	case "vendor":
+		cmd.Vendor(os.Args[2:])
+	case "freeze":
+		cmd.Freeze(os.Args[2:])
+	case "env":
+		cmd.Env(os.Args[2:])
+	case "deactivate":
+		cmd.Deactivate(os.Args[2:])
+	case "try":
+		cmd.Try(os.Args[2:])
+	case "exec":
+		cmd.Exec(os.Args[2:])
+	case "prompt":
+		cmd.Prompt(os.Args[2:])
+	case "run-script":
+		cmd.RunScript(os.Args[2:])
+	case "lint":
+		cmd.Lint(os.Args[2:])
+	case "explain":
+		cmd.Explain(os.Args[2:])
+	case "auth":
+		cmd.Auth(os.Args[2:])
+	case "bug-report":
+		cmd.BugReport(os.Args[2:])
+	case "manifest":
+		cmd.Manifest(os.Args[2:])
+	case "schema":
+		cmd.Schema(os.Args[2:])
+	case "seal":
+		cmd.Seal(os.Args[2:])
+	case "bootstrap-script":
+		cmd.BootstrapScript(os.Args[2:])
 	default:
 		log.Fatalf("Unknown command: %s", command)
 	}
 }
 
+// takeNoColor takes --no-color out of the arguments before the commands parse
+// flags, since it applies to every command. Anything after -- belongs to the
+// command being run, and exec and run-script pass their command's arguments
+// through, so those are left alone; both parse --no-color themselves.
+func takeNoColor(osArgs []string) []string {
+	args := osArgs[:1]
+	for i, arg := range osArgs[1:] {
+		if arg == "--" {
+			return append(args, osArgs[i+1:]...)
+		}
+		if arg == "--no-color" {
+			cmd.DisableColor()
+			continue
+		}
+		args = append(args, arg)
+		if len(args) == 2 && (arg == "exec" || arg == "run-script") {
+			return append(args, osArgs[i+2:]...)
+		}
+	}
+	return args
+}
+
 func printUsage() {
 	fmt.Println("Usage:")
-	fmt.Println("  yourpm switch [config-file]")
+	fmt.Println("  yourpm init [--from-example | --from url] [--force] [--no-hook]")
+	fmt.Println("  yourpm new --template <repo> [--name project] [dir]")
+	fmt.Println("  yourpm switch [--strict] [--gc] [--refresh pkg] [--dry-run] [--tags a,b] [-m message] [config-file | - | url]")
+	fmt.Println("  yourpm plan [-o plan.json] [--tags a,b] [config-file]")
+	fmt.Println("  yourpm apply [-m message] <plan-file>")
+	fmt.Println("  yourpm upgrade [--config file] <package> [version]")
+	fmt.Println("  yourpm downgrade [--config file] <package> [version]")
+	fmt.Println("  yourpm generations [list | diff N M]")
+	fmt.Println("  yourpm use [<environment> | -]")
+	fmt.Println("  yourpm snapshot [list | create <name> | restore <name> | delete <name>]")
+	fmt.Println("  yourpm fetch [--platform os/arch] [-o dir] [config-file]")
+	fmt.Println("  yourpm gc [--dry-run]")
+	fmt.Println("  yourpm report [--since 7d]")
+	fmt.Println("  yourpm undo")
+	fmt.Println("  yourpm restore")
+	fmt.Println("  yourpm restore-backups [--list] [set]")
+	fmt.Println("  yourpm outdated [--config file] [--notify] [--webhook url]")
+	fmt.Println("  yourpm schedule install [--interval daily] [--config file] [--notify] [--webhook url] [--print] | remove")
+	fmt.Println("  yourpm status [--hash] [--expect hash]")
+	fmt.Println("  yourpm repair [package...]")
+	fmt.Println("  yourpm compare <other-state-or-config> [state-or-config]")
+	fmt.Println("  yourpm assert [--config state-or-config] [--allow-extra] [--output json=v1]")
+	fmt.Println("  yourpm list [--provenance]")
+	fmt.Println("  yourpm freeze [-o file]")
+	fmt.Println("  yourpm vendor [config-file]")
+	fmt.Println("  yourpm env [--systemd | --launchd | --output json=v1 | --watch] [config-file]")
+	fmt.Println("  yourpm deactivate [config-file]")
+	fmt.Println("  yourpm exec [--config file] -- <command> [args...]")
+	fmt.Println("  yourpm try <package>[@version] [-- <command> [args...]]")
+	fmt.Println("  yourpm run-script [--config file] [script] [args...]")
+	fmt.Println("  yourpm explain [--config file] <command>")
+	fmt.Println("  yourpm lint [--fix] [--ignore rule] [config-file]")
+	fmt.Println("  yourpm prompt [--dirty mark]")
+	fmt.Println("  yourpm auth login|logout <host>")
+	fmt.Println("  yourpm bug-report [-o file]")
+	fmt.Println("  yourpm manifest check [--config file] [--jobs N] [--rate N]")
+	fmt.Println("  yourpm manifest outdated [--config file] [--patch]")
+	fmt.Println("  yourpm schema dump config|manifest")
+	fmt.Println("  yourpm seal [-r recipient]... < value")
+	fmt.Println("  yourpm bootstrap-script --config url [--init-from url] [--version v] [--pin-config] [-o file]")
+	fmt.Println("")
+	fmt.Println("Every command takes --no-color; NO_COLOR, CLICOLOR and CI are honoured too.")
 	fmt.Println("")
 	fmt.Println("Examples:")
+	fmt.Println("  yourpm init --from-example")
 	fmt.Println("  yourpm switch config.example.toml")
 	fmt.Println("  yourpm switch  # Uses ~/.yourpm/config.toml by default")
+	fmt.Println("  eval \"$(yourpm env)\"")
 }
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+)
+
+// Deviation is one way the live environment differs from what was asserted
+type Deviation struct {
+	Package string `json:"package"`
+	// Problem is "missing", "unexpected", "version", "url", "sha256",
+	// "damaged" (the store entry is gone or incomplete) or "unlinked"
+	Problem  string `json:"problem"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Binary   string `json:"binary,omitempty"`
+}
+
+// assertionVersion is bumped when a field of assert's output changes
+// meaning or goes away
+const assertionVersion = 1
+
+// assertion is what yourpm assert prints
+type assertion struct {
+	Version    int         `json:"version"`
+	OK         bool        `json:"ok"`
+	Expected   string      `json:"expected"`
+	Deviations []Deviation `json:"deviations"`
+}
+
+// Assert checks the live environment against a config or state file
+// without changing anything, printing the deviations as JSON and exiting 1
+// if there are any. It is meant for compliance agents.
+func Assert(args []string) {
+	flags := flag.NewFlagSet("assert", flag.ExitOnError)
+	configFile := flags.String("config", "", "config or state.toml to assert, defaults to the config last switched to")
+	allowExtra := flags.Bool("allow-extra", false, "don't count packages the config doesn't ask for")
+	output := flags.String("output", "json", outputFlagHelp(assertionVersion))
+	flags.Parse(args)
+	outputVersion("assert", *output, assertionVersion)
+
+	baseDir := yourpmDir()
+	applied := loadState(baseDir)
+	if *configFile == "" {
+		*configFile = applied.Config
+	}
+	if *configFile == "" {
+		log.Fatalf("Nothing has been switched to yet, give the config to assert with --config")
+	}
+	expected := loadEnvironment(*configFile, applied)
+
+	var deviations []Deviation
+	for _, d := range state.Compare(applied, expected) {
+		switch {
+		case d.Here == nil:
+			deviations = append(deviations, Deviation{Package: d.Name, Problem: "missing", Expected: d.There.Version})
+		case d.There == nil:
+			if !*allowExtra {
+				deviations = append(deviations, Deviation{Package: d.Name, Problem: "unexpected", Actual: d.Here.Version})
+			}
+		case d.Here.Version != d.There.Version:
+			deviations = append(deviations, Deviation{Package: d.Name, Problem: "version", Expected: d.There.Version, Actual: d.Here.Version})
+		case d.Here.URL != d.There.URL:
+			deviations = append(deviations, Deviation{Package: d.Name, Problem: "url", Expected: d.There.URL, Actual: d.Here.URL})
+		default:
+			deviations = append(deviations, Deviation{Package: d.Name, Problem: "sha256", Expected: d.There.SHA256, Actual: d.Here.SHA256})
+		}
+	}
+
+	// What the state says is applied has to still be there
+	eng := &engine.Engine{Store: store.NewStore(filepath.Join(baseDir, "store")), Previous: applied}
+	for _, name := range eng.Damaged() {
+		deviations = append(deviations, Deviation{Package: name, Problem: "damaged", Actual: applied.Packages[name].Version})
+	}
+	binDir := filepath.Join(baseDir, "profiles", "default", "bin")
+	for _, name := range slices.Sorted(maps.Keys(applied.Packages)) {
+		for _, binary := range applied.Packages[name].Binaries {
+			if _, err := os.Lstat(filepath.Join(binDir, binary)); err != nil {
+				deviations = append(deviations, Deviation{Package: name, Problem: "unlinked", Binary: binary})
+			}
+		}
+	}
+
+	result := assertion{Version: assertionVersion, OK: len(deviations) == 0, Expected: *configFile, Deviations: deviations}
+	if result.Deviations == nil {
+		result.Deviations = []Deviation{}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		log.Fatalf("Failed to write result: %v", err)
+	}
+	if !result.OK {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/crbroughton/pkg-exploration/pkg/repository"
+	"github.com/crbroughton/pkg-exploration/pkg/secrets"
+)
+
+const authUsage = "Usage: yourpm auth login|logout github | registry <host> | <host>"
+
+// Auth manages credentials for download hosts, kept in the OS keychain.
+// Saved tokens are sent to their host by downloads and the GitHub resolver.
+func Auth(args []string) {
+	if len(args) < 2 {
+		log.Fatalf(authUsage)
+	}
+
+	flags := flag.NewFlagSet("auth "+args[0], flag.ExitOnError)
+	clientID := flags.String("client-id", os.Getenv("YOURPM_GITHUB_CLIENT_ID"), "GitHub OAuth app to log in with the device flow")
+	flags.Parse(args[1:])
+
+	host, github := authHost(flags.Args())
+	baseDir := yourpmDir()
+	store, err := secrets.Default(baseDir)
+	if err != nil {
+		log.Fatalf("Can't keep credentials: %v", err)
+	}
+	warnPlaintext(store)
+
+	switch args[0] {
+	case "login":
+		var token string
+		if github && *clientID != "" {
+			// The ca_bundle of the config last switched to, for proxies
+			caBundle = repairSettings(loadState(baseDir)).CABundle
+			token, err = newRepository(baseDir).GitHubDeviceLogin(context.Background(), *clientID, "repo", func(code repository.DeviceCode) {
+				fmt.Printf("Open %s and enter the code %s\n", code.VerificationURI, code.UserCode)
+			})
+		} else {
+			token, err = readSecret(fmt.Sprintf("Token for %s: ", host))
+		}
+		if err != nil {
+			log.Fatalf("Failed to get token: %v", err)
+		}
+		if token == "" {
+			log.Fatalf("No token given")
+		}
+		if err := store.Set(host, token); err != nil {
+			log.Fatalf("Failed to store token: %v", err)
+		}
+		fmt.Printf("%s Saved token for %s in %s\n", sym.ok, host, store.Name())
+	case "logout":
+		if _, err := store.Get(host); errors.Is(err, secrets.ErrNotFound) {
+			fmt.Printf("%s Not logged in to %s\n", sym.info, host)
+			return
+		}
+		if err := store.Delete(host); err != nil {
+			log.Fatalf("Failed to remove token: %v", err)
+		}
+		fmt.Printf("%s Removed token for %s\n", sym.ok, host)
+	default:
+		log.Fatalf("Unknown auth command: %s", args[0])
+	}
+}
+
+// authHost turns auth's arguments into the host a token is saved under
+func authHost(args []string) (host string, github bool) {
+	switch {
+	case len(args) == 1 && args[0] == "github":
+		return "github.com", true
+	case len(args) == 2 && args[0] == "registry":
+		return args[1], false
+	case len(args) == 1:
+		return args[0], args[0] == "github.com"
+	default:
+		log.Fatalf(authUsage)
+		return "", false
+	}
+}
+
+// caBundle is the config's ca_bundle setting, trusted by newRepository
+var caBundle string
+
+// newRepository is the download client, sending tokens saved with yourpm
+// auth login. Each host's token is looked up once.
+func newRepository(baseDir string) *repository.HttpRepository {
+	repo, err := openRepository(baseDir)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return repo
+}
+
+// openRepository is newRepository returning its error, for paths that must
+// not exit
+func openRepository(baseDir string) (*repository.HttpRepository, error) {
+	repo := repository.NewHttpRepository(filepath.Join(baseDir, "cache"))
+	if caBundle != "" {
+		if err := repo.TrustCertificates(os.ExpandEnv(caBundle)); err != nil {
+			return nil, fmt.Errorf("failed to load ca_bundle: %w", err)
+		}
+	}
+	store, err := secrets.Default(baseDir)
+	if err != nil {
+		// Without a keychain nothing can have been saved
+		return repo, nil
+	}
+
+	var mu sync.Mutex
+	var warned bool
+	tokens := make(map[string]string)
+	repo.UseCredentials(func(host string) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		token, ok := tokens[host]
+		if !ok {
+			token, _ = store.Get(host)
+			tokens[host] = token
+			if token != "" && !warned {
+				warnPlaintext(store)
+				warned = true
+			}
+		}
+		return token
+	})
+	return repo, nil
+}
+
+// warnPlaintext says loudly when tokens are kept unencrypted on disk
+func warnPlaintext(store secrets.Store) {
+	if secrets.Plaintext(store) {
+		fmt.Fprintf(os.Stderr, "%s WARNING: credentials are stored UNENCRYPTED in %s because %s is set and there is no OS keychain\n", sym.warn, store.Name(), secrets.PlaintextEnv)
+	}
+}
+
+// readSecret reads a line from stdin, without echoing it when stdin is a terminal
+func readSecret(prompt string) (string, error) {
+	if isTerminal(os.Stdin) {
+		fmt.Fprint(os.Stderr, prompt)
+		if err := stty("-echo"); err == nil {
+			defer func() {
+				stty("echo")
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func stty(arg string) error {
+	c := exec.Command("stty", arg)
+	c.Stdin = os.Stdin
+	return c.Run()
+}
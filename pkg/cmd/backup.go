@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+)
+
+// RestoreBackups puts back user files that linking moved aside, from the
+// latest backup set unless one is named
+func RestoreBackups(args []string) {
+	flags := flag.NewFlagSet("restore-backups", flag.ExitOnError)
+	list := flags.Bool("list", false, "list the backup sets instead of restoring one")
+	flags.Parse(args)
+
+	prof := profile.NewProfile(filepath.Join(yourpmDir(), "profiles", "default"))
+	sets, err := prof.BackupSets()
+	if err != nil {
+		log.Fatalf("Failed to read backups: %v", err)
+	}
+	if len(sets) == 0 {
+		fmt.Println("No backups to restore")
+		return
+	}
+
+	if *list {
+		for _, set := range sets {
+			fmt.Println(set)
+		}
+		return
+	}
+
+	set := sets[len(sets)-1]
+	if flags.NArg() > 0 {
+		set = flags.Arg(0)
+	}
+
+	restored, err := prof.RestoreBackups(set)
+	if err != nil {
+		log.Fatalf("Failed to restore backup %s: %v", set, err)
+	}
+	for _, binary := range restored {
+		fmt.Printf("%s Restored %s\n", sym.ok, binary)
+	}
+}
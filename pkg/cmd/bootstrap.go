@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultReleaseURL is where yourpm binaries are published, {version}, {os}
+// and {arch} are filled in per platform
+const defaultReleaseURL = "https://github.com/crbroughton/pkg-exploration/releases/download/v{version}/yourpm-{os}-{arch}"
+
+// bootstrapPlatforms are the platforms a bootstrap script can provision
+var bootstrapPlatforms = []string{"linux-amd64", "linux-arm64", "darwin-amd64", "darwin-arm64"}
+
+// BootstrapScript prints a POSIX script that installs yourpm, checking the
+// binary against the sha256 it had when the script was generated, then
+// runs init and switches to the given config
+func BootstrapScript(args []string) {
+	flags := flag.NewFlagSet("bootstrap-script", flag.ExitOnError)
+	configURL := flags.String("config", "", "URL of the config to switch to")
+	initFrom := flags.String("init-from", "", "base URL init fetches config.toml and manifest.toml from")
+	version := flags.String("version", Version, "yourpm release to install")
+	releaseURL := flags.String("release-url", defaultReleaseURL, "URL of the yourpm binary, with {version}, {os} and {arch}")
+	pinConfig := flags.Bool("pin-config", false, "also pin the config to its current sha256")
+	output := flags.String("o", "", "write the script to this file instead of stdout")
+	flags.Parse(args)
+
+	if *configURL == "" {
+		log.Fatalf("Usage: yourpm bootstrap-script --config <url> [--init-from url] [--version v] [--pin-config] [-o file]")
+	}
+	if *version == "dev" {
+		log.Fatalf("This is a development build, pass --version for the release to install")
+	}
+
+	// fetchRemote downloads into the configs dir
+	baseDir := yourpmDir()
+	if err := os.MkdirAll(filepath.Join(baseDir, "configs"), 0755); err != nil {
+		log.Fatalf("Failed to create %s: %v", filepath.Join(baseDir, "configs"), err)
+	}
+
+	digests := make(map[string]string)
+	for _, platform := range bootstrapPlatforms {
+		goos, goarch, _ := strings.Cut(platform, "-")
+		url := strings.NewReplacer("{version}", *version, "{os}", goos, "{arch}", goarch).Replace(*releaseURL)
+		sum := sha256.Sum256(fetchRemote(baseDir, url))
+		digests[platform] = hex.EncodeToString(sum[:])
+	}
+
+	var switchArgs []string
+	if *pinConfig {
+		sum := sha256.Sum256(fetchRemote(baseDir, *configURL))
+		switchArgs = append(switchArgs, "--sha256", hex.EncodeToString(sum[:]))
+	}
+	switchArgs = append(switchArgs, shellWord(*configURL))
+
+	initArgs := ""
+	if *initFrom != "" {
+		initArgs = " --from " + shellWord(*initFrom)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Generated by yourpm bootstrap-script: installs yourpm %s and switches to\n# %s\n", *version, *configURL)
+	b.WriteString("set -eu\n\n")
+	b.WriteString(`case "$(uname -s)" in
+Linux) os=linux ;;
+Darwin) os=darwin ;;
+*) echo "Unsupported OS: $(uname -s)" >&2; exit 1 ;;
+esac
+case "$(uname -m)" in
+x86_64 | amd64) arch=amd64 ;;
+aarch64 | arm64) arch=arm64 ;;
+*) echo "Unsupported architecture: $(uname -m)" >&2; exit 1 ;;
+esac
+
+case "$os-$arch" in
+`)
+	for _, platform := range bootstrapPlatforms {
+		fmt.Fprintf(&b, "%s) sha256=%s ;;\n", platform, digests[platform])
+	}
+	b.WriteString("esac\n\n")
+
+	url := strings.NewReplacer("{version}", *version, "{os}", "${os}", "{arch}", "${arch}").Replace(*releaseURL)
+	fmt.Fprintf(&b, "url=\"%s\"\n", shellQuoteDouble(url))
+	b.WriteString(`bindir="${YOURPM_BIN_DIR:-$HOME/.local/bin}"
+tmp="$(mktemp)"
+trap 'rm -f "$tmp"' EXIT
+
+echo "Downloading $url"
+curl -fsSL "$url" -o "$tmp"
+if command -v sha256sum >/dev/null 2>&1; then
+	actual="$(sha256sum "$tmp" | cut -d' ' -f1)"
+else
+	actual="$(shasum -a 256 "$tmp" | cut -d' ' -f1)"
+fi
+if [ "$actual" != "$sha256" ]; then
+	echo "yourpm download has sha256 $actual, expected $sha256" >&2
+	exit 1
+fi
+
+mkdir -p "$bindir"
+chmod 755 "$tmp"
+mv "$tmp" "$bindir/yourpm"
+
+`)
+	fmt.Fprintf(&b, "\"$bindir/yourpm\" init%s\n", initArgs)
+	fmt.Fprintf(&b, "\"$bindir/yourpm\" switch %s\n", strings.Join(switchArgs, " "))
+
+	if *output == "" {
+		fmt.Print(b.String())
+		return
+	}
+	if err := os.WriteFile(*output, []byte(b.String()), 0755); err != nil {
+		log.Fatalf("Failed to write %s: %v", *output, err)
+	}
+	fmt.Printf("%s Wrote %s\n", sym.ok, *output)
+}
+
+// shellWord single quotes a value for a POSIX shell
+func shellWord(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// shellQuoteDouble escapes a value for use inside double quotes, leaving
+// the ${os} and ${arch} references to expand
+func shellQuoteDouble(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`").Replace(value)
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// secretValue matches a credential key, the = or : after it, and its value
+// in any TOML or JSON string form. The forms are one alternation so each
+// value is matched once: the multi-line ones come first, or a """ would be
+// taken for an empty "" followed by the secret.
+var secretValue = regexp.MustCompile(`(?i)((?:token|secret|password|passwd|api[_-]?key|auth\w*)["']?\s*[=:]\s*)` +
+	`(?s:(""").*?"""|(''').*?'''|(")(?:[^"\\\n]|\\.)*"|(')[^'\n]*')`)
+
+// redactions match things that look like credentials, with what replaces
+// them
+var redactions = []struct {
+	pattern *regexp.Regexp
+	replace string
+}{
+	{secretValue, `${1}${2}${3}${4}${5}REDACTED${2}${3}${4}${5}`},
+	{regexp.MustCompile(`://[^/@\s"']+@`), `://REDACTED@`},
+	{regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,})`), `REDACTED`},
+	{regexp.MustCompile(`\bgl(?:pat|oas|dt|rt|ptt|ft|cbt|imt)-[A-Za-z0-9_-]{20,}`), `REDACTED`},
+	{regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), `REDACTED`},
+	{regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`), `REDACTED`},
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/-]+=*`), `${1}REDACTED`},
+}
+
+// failureLog is where RecordFailures keeps the last fatal error
+const failureLog = "last-failure.log"
+
+// logTail is how much of a log file goes into the report
+const logTail = 200
+
+// BugReport bundles what is needed to look into a problem into a tarball,
+// with credentials and the home dir redacted
+func BugReport(args []string) {
+	flags := flag.NewFlagSet("bug-report", flag.ExitOnError)
+	output := flags.String("o", "", "where to write the tarball, defaults to yourpm-bug-report-<time>.tar.gz")
+	flags.Parse(args)
+
+	if *output == "" {
+		*output = fmt.Sprintf("yourpm-bug-report-%s.tar.gz", time.Now().Format("20060102-150405"))
+	}
+
+	baseDir := yourpmDir()
+	files := map[string]string{
+		"system.txt": systemInfo(),
+	}
+
+	applied := loadState(baseDir)
+	addFile(files, "state.toml", statePath(baseDir))
+	if applied.Config != "" {
+		addFile(files, "config.toml", applied.Config)
+	}
+	addFile(files, "manifest.toml", filepath.Join(baseDir, "manifest.toml"))
+	dropIns, _ := filepath.Glob(filepath.Join(baseDir, "manifest.d", "*.toml"))
+	for _, path := range dropIns {
+		addFile(files, filepath.Join("manifest.d", filepath.Base(path)), path)
+	}
+	if data, err := os.ReadFile(filepath.Join(baseDir, "schedule.log")); err == nil {
+		lines := strings.Split(string(data), "\n")
+		if len(lines) > logTail {
+			lines = lines[len(lines)-logTail:]
+		}
+		files["schedule.log"] = strings.Join(lines, "\n")
+	}
+	files["bin.txt"] = binListing(filepath.Join(baseDir, "profiles", "default", "bin"))
+	if _, err := os.Stat(filepath.Join(baseDir, failureLog)); err == nil {
+		addFile(files, failureLog, filepath.Join(baseDir, failureLog))
+	}
+
+	if err := writeReport(*output, files); err != nil {
+		log.Fatalf("Failed to write %s: %v", *output, err)
+	}
+	fmt.Printf("%s Wrote %s\n", sym.ok, *output)
+	fmt.Println("Credentials and your home dir are redacted, but look it over before sharing it.")
+}
+
+// RecordFailures makes the fatal error a command exits with also go to
+// last-failure.log, with the command line and a stack trace, for bug-report
+// to pick up. Commands fail through log.Fatalf, so that covers them.
+func RecordFailures(args []string) {
+	log.SetOutput(failureRecorder{args: args})
+}
+
+type failureRecorder struct {
+	args []string
+}
+
+func (f failureRecorder) Write(p []byte) (int, error) {
+	n, err := os.Stderr.Write(p)
+
+	// Only record into an existing yourpm dir, never create one
+	baseDir := yourpmDir()
+	if info, statErr := os.Stat(baseDir); statErr == nil && info.IsDir() {
+		var b strings.Builder
+		fmt.Fprintf(&b, "time: %s\n", time.Now().Format(time.RFC3339))
+		fmt.Fprintf(&b, "command: %s\n\n", strings.Join(f.args, " "))
+		fmt.Fprintf(&b, "%s\n%s", p, debug.Stack())
+		os.WriteFile(filepath.Join(baseDir, failureLog), []byte(b.String()), 0600)
+	}
+	return n, err
+}
+
+func addFile(files map[string]string, name string, path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		files[name] = fmt.Sprintf("# could not read %s: %v\n", path, err)
+		return
+	}
+	files[name] = string(data)
+}
+
+func systemInfo() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "yourpm: %s\n", Version)
+	fmt.Fprintf(&b, "go: %s\n", runtime.Version())
+	fmt.Fprintf(&b, "platform: %s-%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "shell: %s\n", os.Getenv("SHELL"))
+	for _, tool := range []string{"tar", "pigz", "git", "bwrap", "sandbox-exec"} {
+		path, err := exec.LookPath(tool)
+		if err != nil {
+			path = "not found"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", tool, path)
+	}
+	return b.String()
+}
+
+func binListing(binDir string) string {
+	entries, err := os.ReadDir(binDir)
+	if err != nil {
+		return fmt.Sprintf("# could not read %s: %v\n", binDir, err)
+	}
+
+	var b strings.Builder
+	for _, entry := range entries {
+		path := filepath.Join(binDir, entry.Name())
+		if dest, err := os.Readlink(path); err == nil {
+			fmt.Fprintf(&b, "%s -> %s\n", entry.Name(), dest)
+			continue
+		}
+		fmt.Fprintf(&b, "%s\n", entry.Name())
+	}
+	return b.String()
+}
+
+// redact strips credentials and replaces the home dir with ~
+func redact(content string) string {
+	for _, secret := range redactions {
+		content = secret.pattern.ReplaceAllString(content, secret.replace)
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil && homeDir != "" && homeDir != "/" {
+		content = strings.ReplaceAll(content, homeDir, "~")
+	}
+	return content
+}
+
+func writeReport(path string, files map[string]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	prefix := strings.TrimSuffix(filepath.Base(path), ".tar.gz")
+	now := time.Now()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content := redact(files[name])
+		header := &tar.Header{
+			Name:    filepath.Join(prefix, name),
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -2,95 +2,344 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
 	"github.com/crbroughton/pkg-exploration/pkg/manifest"
 	"github.com/crbroughton/pkg-exploration/pkg/profile"
-	"github.com/crbroughton/pkg-exploration/pkg/repository"
+	"github.com/crbroughton/pkg-exploration/pkg/sealed"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
 	"github.com/crbroughton/pkg-exploration/pkg/store"
 )
 
 func Switch(args []string) {
-	homeDir, _ := os.UserHomeDir()
-	baseDir := filepath.Join(homeDir, ".yourpm")
+	flags := flag.NewFlagSet("switch", flag.ExitOnError)
+	strict := flags.Bool("strict", false, "fail if a command is shadowed by another binary earlier in PATH")
+	gc := flags.Bool("gc", false, "delete store entries of packages dropped from the config")
+	message := flags.String("m", "", "note recorded with the generation this switch creates")
+	dryRun := flags.Bool("dry-run", false, "show what would change and how much would be downloaded, then exit")
+	expectSHA256 := flags.String("sha256", "", "with a config from stdin or a URL, the sha256 it must have")
+	minisignKey := flags.String("minisign-key", "", "with a config URL, the minisign public key its .minisig must verify against")
+	tags := flags.String("tags", "", "comma separated tags selecting the config's [hosts.\"tag:...\"] sections")
+	var refresh stringsFlag
+	flags.Var(&refresh, "refresh", "re-download a package even if it is cached (repeatable)")
+	flags.Parse(args)
 
-	manifestPath := filepath.Join(baseDir, "manifest.toml")
-	mfst, err := manifest.LoadManifest(manifestPath)
-	if err != nil {
-		log.Fatalf("Failed to load manifest: %v\n", err)
-		log.Fatalf("Make sure %s exists", manifestPath)
-	}
-
-	// Load config (what user wants)
-	// Default to ~/.yourpm/config.toml, but allow override
-	configPath := filepath.Join(baseDir, "config.toml")
-	if len(args) > 0 {
-		configPath = args[0]
-		// Make path absolute if it's relative
-		if !filepath.IsAbs(configPath) {
-			pwd, _ := os.Getwd()
-			configPath = filepath.Join(pwd, configPath)
-		}
+	baseDir := yourpmDir()
+	if *tags != "" {
+		tagsFlag = splitTags(*tags)
 	}
 
-	cfg, err := config.LoadConfig(configPath)
-	if err != nil {
-		log.Fatalf("Failed to load config from %s: %v", configPath, err)
+	configArgs := flags.Args()
+	if len(configArgs) > 0 && remoteConfig(configArgs[0]) {
+		configArgs = []string{materializeConfig(baseDir, configArgs[0], *expectSHA256, *minisignKey)}
 	}
+	configPath, cfg := loadConfig(baseDir, configArgs)
+	mfst := loadManifest(baseDir, cfg)
 
 	fmt.Printf("Loading config from: %s\n", configPath)
 	fmt.Printf("Applying environment: %s\n", cfg.Name)
 	fmt.Printf("Packages to install: %d\n\n", len(cfg.Packages))
 
 	ctx := context.Background()
-	repo := repository.NewHttpRepository(filepath.Join(baseDir, "cache"))
-	st := store.NewStore(filepath.Join(baseDir, "store"))
 	prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
 
-	installedPaths := make(map[string]string)
+	if *strict || cfg.Settings.Strictness == config.StrictnessStrict {
+		checkShadowing(mfst, cfg, prof)
+	}
+
+	if swept, freed := sweepLeftovers(baseDir, *dryRun); len(swept) > 0 && !*dryRun {
+		fmt.Printf("Cleaned up %d temp files left by interrupted runs (%s)\n\n", len(swept), engine.FormatBytes(uint64(freed)))
+	}
 
-	// Install each package
-	for name, version := range cfg.Packages {
-		fmt.Printf("📦 %s@%s\n", name, version)
+	eng := newEngine(baseDir, mfst, prof)
+	eng.Refresh = refresh.set()
+	eng.GC = *gc
 
-		url, err := mfst.GetURL(name, version)
+	if *dryRun {
+		plan, err := eng.Plan(ctx, cfg)
 		if err != nil {
-			log.Fatalf("  ✗ Failed to get URL: %v", err)
+			log.Fatalf("%s %v", sym.fail, err)
 		}
+		printPlan(plan)
+		fmt.Println("\nDry run: nothing was changed.")
+		return
+	}
+
+	applied := applyOrExit(ctx, eng, configPath, cfg)
+	recordGeneration(baseDir, configPath, applied, *message)
+
+	fmt.Printf("%s Environment '%s' is now active\n\n", sym.ok, cfg.Name)
+	fmt.Printf("Ensure this is in your shell profile:\n")
+	fmt.Printf("  . \"%s\"\n", prof.ActivationPath())
+}
 
-		pkgDef, _ := mfst.GetPackage(name)
+// newEngine wires an engine to the yourpm dirs under baseDir and the last applied state
+func newEngine(baseDir string, mfst *manifest.Manifest, prof *profile.Profile) *engine.Engine {
+	return &engine.Engine{
+		BaseDir:  baseDir,
+		Manifest: mfst,
+		Repo:     newRepository(baseDir),
+		Store:    store.NewStore(filepath.Join(baseDir, "store")),
+		Profile:  prof,
+		Observer: newConsole(),
+		Previous: loadState(baseDir),
+	}
+}
 
-		// Start the download
-		filename := filepath.Base(url)
-		cachePath := filepath.Join(baseDir, "cache", fmt.Sprintf("%s-%s-%s", name, version, filename))
+// applyOrExit applies cfg and records the result: the activation script and
+// the state. Any failure is fatal.
+func applyOrExit(ctx context.Context, eng *engine.Engine, configPath string, cfg *config.Config) *state.State {
+	warnSharedBinaries(eng.Manifest, cfg)
 
-		if err := repo.DownloadFile(ctx, url, cachePath); err != nil {
-			log.Fatalf("  ✗ Download failed: %v", err)
+	applied, err := eng.Apply(ctx, cfg, configPath)
+	if applied != nil {
+		applied.Tags = hostTags(eng.Previous)
+		applied.ConfigHash = configHash(configPath)
+	}
+	var pkgErr *engine.PackageError
+	if errors.As(err, &pkgErr) {
+		log.Fatalf("  %s %v", sym.fail, pkgErr.Err)
+	}
+	if err != nil {
+		log.Fatalf("%s %v", sym.fail, err)
+	}
+	if len(eng.Broken) > 0 {
+		fmt.Fprintf(os.Stderr, "%s %d commands were linked but won't run:\n", sym.warn, len(eng.Broken))
+		for _, link := range eng.Broken {
+			fmt.Fprintf(os.Stderr, "  %s (%s): %s\n", link.Binary, link.Package, link.Cause)
 		}
-		fmt.Printf("  ✓ Downloaded\n")
+	}
+	for _, backup := range eng.Profile.Backups() {
+		warn([]string{fmt.Sprintf("Moved %s, which yourpm didn't create, to %s (yourpm restore-backups puts it back)", filepath.Base(backup), backup)})
+	}
 
-		// Install - pass binary names so it knows what to search for
-		storePath, err := st.Install(name, version, cachePath, pkgDef.Binaries.Names)
-		if err != nil {
-			log.Fatalf("  ✗ Install failed: %v", err)
+	for _, command := range slices.Sorted(maps.Keys(cfg.Wrappers)) {
+		if _, ok := applied.Owner(command); !ok {
+			warn([]string{fmt.Sprintf("wrappers.%s: no package in the config provides %s", command, command)})
 		}
-		fmt.Printf("  ✓ Installed\n")
+	}
+
+	_, envWarnings := applied.Env()
+	warn(envWarnings)
+	if err := eng.Profile.WriteActivation(activation(cfg, applied)); err != nil {
+		log.Fatalf("Failed to write activation script: %v", err)
+	}
+	if err := eng.Profile.WriteFingerprint(configPath, applied.ConfigHash); err != nil {
+		log.Fatalf("Failed to write fingerprint: %v", err)
+	}
+
+	if err := applied.Save(statePath(eng.BaseDir)); err != nil {
+		log.Fatalf("Failed to save state: %v", err)
+	}
+	recordSwitch(eng.BaseDir, eng.Previous, applied, eng.Downloaded)
+	saveEnvironment(eng.BaseDir, eng.Previous, applied)
+	return applied
+}
+
+// warnSharedBinaries says whose binary is linked when several packages in
+// cfg ship one of the same name and the config hasn't picked. In error mode
+// the engine refuses instead.
+func warnSharedBinaries(mfst *manifest.Manifest, cfg *config.Config) {
+	if cfg.Settings.DuplicateBinaries == config.DuplicateBinariesError {
+		return
+	}
 
-		installedPaths[name] = storePath
+	var names []string
+	for name := range cfg.Packages {
+		if _, err := mfst.GetPackage(name); err == nil {
+			names = append(names, name)
+		}
+	}
+	owners, shared, err := mfst.BinaryOwners(names, cfg.Settings.Providers)
+	if err != nil {
+		return
+	}
 
-		// Do the symlinking stuff
-		if err := prof.Link(storePath, pkgDef.Binaries.Names); err != nil {
-			log.Fatalf("  ✗ Link failed: %v", err)
+	var warnings []string
+	for binary, providers := range shared {
+		if _, ok := cfg.Settings.Providers[binary]; ok {
+			continue
 		}
-		fmt.Printf("  ✓ Linked\n\n")
+		warnings = append(warnings, fmt.Sprintf("%s is shipped by %s, linking %s's (pick one with [settings.providers] %s = \"...\")",
+			binary, strings.Join(providers, " and "), owners[binary], binary))
+	}
+	sort.Strings(warnings)
+	warn(warnings)
+}
+
+// stringsFlag collects a flag that can be given more than once
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func (f stringsFlag) set() map[string]bool {
+	set := make(map[string]bool, len(f))
+	for _, value := range f {
+		set[value] = true
 	}
+	return set
+}
+
+func statePath(baseDir string) string {
+	return filepath.Join(baseDir, "state.toml")
+}
 
-	profileBin := filepath.Join(baseDir, "profiles", "default", "bin")
-	fmt.Printf("✓ Environment '%s' is now active\n\n", cfg.Name)
-	fmt.Printf("Ensure this is in your PATH:\n")
-	fmt.Printf("  export PATH=\"%s:$PATH\"\n", profileBin)
+// checkShadowing aborts the switch when any exposed command would be shadowed
+func checkShadowing(mfst *manifest.Manifest, cfg *config.Config, prof *profile.Profile) {
+	var binaries []string
+	for name := range cfg.Packages {
+		if pkgDef, err := mfst.GetPackage(name); err == nil {
+			binaries = append(binaries, pkgDef.Binaries.Names...)
+		}
+	}
+
+	shadowed := prof.Shadowed(activation(cfg, &state.State{}), os.Getenv("PATH"), binaries)
+	if len(shadowed) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(shadowed))
+	for binary := range shadowed {
+		names = append(names, binary)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("%s Strict mode: commands shadowed earlier in PATH\n", sym.fail)
+	for _, binary := range names {
+		fmt.Printf("  %s -> %s\n", binary, shadowed[binary])
+	}
+	os.Exit(1)
+}
+
+func yourpmDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".yourpm")
+}
+
+// loadManifest loads the manifest and its manifest.d drop-ins, or the
+// config's vendored manifest, treating unknown keys as the config's
+// strictness says
+func loadManifest(baseDir string, cfg *config.Config) *manifest.Manifest {
+	if vendored := cfg.VendoredManifest(); vendored != "" {
+		return loadManifestFrom(baseDir, vendored, "", cfg)
+	}
+	return loadManifestFrom(baseDir, filepath.Join(baseDir, "manifest.toml"), filepath.Join(baseDir, "manifest.d"), cfg)
+}
+
+// loadManifestFrom is loadManifest for a manifest kept elsewhere, like in a snapshot
+func loadManifestFrom(baseDir string, manifestPath string, dropInDir string, cfg *config.Config) *manifest.Manifest {
+	mfst, err := manifest.LoadManifestWithOverlays(manifestPath, dropInDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("Failed to load manifest: %v\nMake sure %s exists", err, manifestPath)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load manifest: %v", err)
+	}
+	if err := sealed.Open(mfst, ageIdentity(baseDir)); err != nil {
+		log.Fatalf("Failed to decrypt manifest values: %v", err)
+	}
+	deprecated(mfst.Deprecations)
+
+	switch cfg.Settings.Strictness {
+	case config.StrictnessStrict:
+		if len(mfst.Warnings) > 0 {
+			log.Fatalf("Failed to load manifest: %s", mfst.Warnings[0])
+		}
+	case config.StrictnessWarn:
+		warn(mfst.Warnings)
+	}
+	return mfst
+}
+
+// loadConfig loads the config (what the user wants).
+// Defaults to ~/.yourpm/config.toml, but the first argument can override it.
+func loadConfig(baseDir string, args []string) (string, *config.Config) {
+	configPath := configPathFrom(baseDir, args)
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config from %s: %v", configPath, err)
+	}
+	if err := sealed.Open(cfg, ageIdentity(baseDir)); err != nil {
+		log.Fatalf("Failed to decrypt config values in %s: %v", configPath, err)
+	}
+	applyHosts(cfg, hostTags(loadState(baseDir)))
+	setOutputStyle(cfg.Settings.Output)
+	setProgress(cfg.Settings.Progress, cfg.Packages)
+	caBundle = cfg.Settings.CABundle
+	warn(cfg.Warnings)
+	deprecated(cfg.Deprecations)
+	return configPath, cfg
+}
+
+// configPathFrom is the absolute path of the config named by the first
+// argument, or ~/.yourpm/config.toml without one
+func configPathFrom(baseDir string, args []string) string {
+	if len(args) == 0 {
+		return filepath.Join(baseDir, "config.toml")
+	}
+	configPath := args[0]
+	// Make path absolute if it's relative
+	if !filepath.IsAbs(configPath) {
+		pwd, _ := os.Getwd()
+		configPath = filepath.Join(pwd, configPath)
+	}
+	return configPath
+}
+
+// warn prints to stderr so commands whose stdout is consumed stay clean
+func warn(warnings []string) {
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "%s %s\n", sym.warn, warning)
+	}
+}
+
+// configArgs adapts an optional --config flag to loadConfig's arguments
+func configArgs(configFile string) []string {
+	if configFile == "" {
+		return nil
+	}
+	return []string{configFile}
+}
+
+// activation combines the config's env and PATH with the env exported by
+// the applied packages. The config wins where both set a variable.
+func activation(cfg *config.Config, applied *state.State) profile.Activation {
+	env, _ := applied.Env()
+	for key, value := range cfg.Env {
+		env[key] = value
+	}
+
+	return profile.Activation{
+		Env:         env,
+		PathPrepend: cfg.PathPrepend,
+		PathAppend:  cfg.PathAppend,
+	}
+}
+
+// loadState loads the state of the last switch
+func loadState(baseDir string) *state.State {
+	applied, err := state.Load(statePath(baseDir))
+	if err != nil {
+		log.Fatalf("Failed to load state: %v", err)
+	}
+	return applied
 }
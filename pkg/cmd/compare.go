@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+)
+
+// Compare diffs the applied environment against another machine's, given
+// as its state.toml or a frozen config, and exits 1 if they drifted
+func Compare(args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		log.Fatalf("Usage: yourpm compare <other-state-or-config> [state-or-config]")
+	}
+
+	here := loadState(yourpmDir())
+	hereName := "here"
+	if len(args) == 2 {
+		here = loadEnvironment(args[1], nil)
+		hereName = args[1]
+	}
+	there := loadEnvironment(args[0], nil)
+
+	drift := state.Compare(here, there)
+	if len(drift) == 0 {
+		fmt.Printf("%s %s and %s run the same packages\n", sym.ok, hereName, args[0])
+		return
+	}
+
+	fmt.Printf("Comparing %s with %s:\n", hereName, args[0])
+	for _, d := range drift {
+		switch {
+		case d.Here == nil:
+			fmt.Printf("+ %s %s only in %s\n", d.Name, d.There.Version, args[0])
+		case d.There == nil:
+			fmt.Printf("- %s %s only in %s\n", d.Name, d.Here.Version, hereName)
+		case d.Here.Version != d.There.Version:
+			fmt.Printf("~ %s %s %s %s\n", d.Name, d.Here.Version, sym.arrow, d.There.Version)
+		case d.Here.URL != d.There.URL:
+			fmt.Printf("~ %s %s downloaded from different URLs\n    %s\n    %s\n", d.Name, d.Here.Version, d.Here.URL, d.There.URL)
+		default:
+			fmt.Printf("~ %s %s has different contents\n    sha256 %s\n    sha256 %s\n", d.Name, d.Here.Version, d.Here.SHA256, d.There.SHA256)
+		}
+	}
+	os.Exit(1)
+}
+
+// loadEnvironment reads a state.toml, or failing that a config, whose
+// packages then only have versions to compare. A config gets the hosts
+// sections of the machine applied describes, unless applied is nil, as for
+// a config from another machine.
+func loadEnvironment(path string, applied *state.State) *state.State {
+	if s, err := state.Load(path); err == nil && len(s.Packages) > 0 {
+		return s
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		log.Fatalf("Failed to read %s as a state or config: %v", path, err)
+	}
+	if applied != nil {
+		applyHosts(cfg, hostTags(applied))
+	}
+
+	s := &state.State{Environment: cfg.Name, Packages: make(map[string]state.PackageState, len(cfg.Packages))}
+	for name, version := range cfg.Packages {
+		s.Packages[name] = state.PackageState{Version: version}
+	}
+	return s
+}
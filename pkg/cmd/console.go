@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
+)
+
+// console renders engine events as the CLI's text output. Packages install
+// concurrently, so each one's lines are buffered and printed as a block when
+// the engine links it, which happens in name order, or with prefixed progress
+// are printed straight away behind the package name. While downloads run, a
+// spinner on stderr shows their progress.
+type console struct {
+	engine.NopObserver
+
+	mu     sync.Mutex
+	blocks map[string]*strings.Builder
+
+	// downloads holds bytes done and total of downloads in flight
+	downloads map[string][2]int64
+	spinning  bool
+	drawn     bool
+	frame     int
+}
+
+// prefixed and prefixWidth are set from the config's progress setting, with
+// YOURPM_PROGRESS taking precedence
+var (
+	prefixed    = os.Getenv("YOURPM_PROGRESS") == config.ProgressPrefixed
+	prefixWidth int
+)
+
+// setProgress picks the console layout and pads prefixes to the longest
+// package name so lines from different packages stay aligned
+func setProgress(style string, packages map[string]string) {
+	if os.Getenv("YOURPM_PROGRESS") == "" {
+		prefixed = style == config.ProgressPrefixed
+	}
+	for name := range packages {
+		prefixWidth = max(prefixWidth, len(name))
+	}
+}
+
+func newConsole() *console {
+	return &console{
+		blocks:    make(map[string]*strings.Builder),
+		downloads: make(map[string][2]int64),
+	}
+}
+
+func (c *console) printf(name string, format string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if prefixed {
+		c.clearSpinner()
+		for _, line := range strings.Split(fmt.Sprintf(format, args...), "\n") {
+			if strings.TrimSpace(line) != "" {
+				fmt.Printf("%-*s | %s\n", prefixWidth, name, strings.TrimLeft(line, " "))
+			}
+		}
+		return
+	}
+
+	block, ok := c.blocks[name]
+	if !ok {
+		block = &strings.Builder{}
+		c.blocks[name] = block
+	}
+	fmt.Fprintf(block, format, args...)
+}
+
+// flush prints and forgets a package's buffered lines
+func (c *console) flush(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.downloads, name)
+	if block, ok := c.blocks[name]; ok {
+		c.clearSpinner()
+		fmt.Print(block.String())
+		delete(c.blocks, name)
+	}
+}
+
+func (c *console) OnPackageStart(name string, version string) {
+	c.printf(name, "%s %s@%s\n", sym.pkg, name, version)
+}
+
+func (c *console) OnCacheInvalidated(name string, reason error) {
+	if reason == nil {
+		c.printf(name, "  %s Cleared cached download\n", sym.ok)
+		return
+	}
+	c.printf(name, "  %s Cached download is unusable (%v), fetching again\n", sym.warn, reason)
+}
+
+func (c *console) OnDownloadProgress(name string, done int64, total int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.downloads[name] = [2]int64{done, total}
+	if !c.spinning && animate() {
+		c.spinning = true
+		go c.spin()
+	}
+}
+
+func (c *console) OnDownloaded(name string) {
+	c.mu.Lock()
+	delete(c.downloads, name)
+	c.mu.Unlock()
+
+	c.printf(name, "  %s Downloaded\n", sym.ok)
+}
+
+func (c *console) OnInstalled(name string) {
+	c.printf(name, "  %s Installed\n", sym.ok)
+}
+
+func (c *console) OnLinked(name string, binaries []string) {
+	c.printf(name, "  %s Linked\n\n", sym.ok)
+	c.flush(name)
+}
+
+func (c *console) OnUnlinked(name string, binaries []string) {
+	c.printf(name, "%s %s no longer provides %s\n\n", sym.removed, name, strings.Join(binaries, ", "))
+	c.flush(name)
+}
+
+func (c *console) OnRemoved(name string, version string, fromStore bool) {
+	c.printf(name, "%s %s@%s\n", sym.removed, name, version)
+	c.printf(name, "  %s Unlinked\n", sym.ok)
+	if fromStore {
+		c.printf(name, "  %s Removed from store\n", sym.ok)
+	}
+	c.printf(name, "\n")
+	c.flush(name)
+}
+
+func (c *console) OnError(name string, err error) {
+	c.flush(name)
+}
+
+// spin redraws the spinner line until no downloads are left
+func (c *console) spin() {
+	frames := []rune(sym.spinner)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.mu.Lock()
+		if len(c.downloads) == 0 {
+			c.clearSpinner()
+			c.spinning = false
+			c.mu.Unlock()
+			return
+		}
+
+		names := make([]string, 0, len(c.downloads))
+		for name := range c.downloads {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		parts := make([]string, len(names))
+		for i, name := range names {
+			done, total := c.downloads[name][0], c.downloads[name][1]
+			if total > 0 {
+				parts[i] = fmt.Sprintf("%s %d%%", name, done*100/total)
+			} else {
+				parts[i] = fmt.Sprintf("%s %s", name, engine.FormatBytes(uint64(done)))
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "\r\x1b[K%c Downloading %s", frames[c.frame%len(frames)], strings.Join(parts, ", "))
+		c.frame++
+		c.drawn = true
+		c.mu.Unlock()
+	}
+}
+
+// clearSpinner erases the spinner line so other output starts clean. The
+// caller holds c.mu.
+func (c *console) clearSpinner() {
+	if c.drawn {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+		c.drawn = false
+	}
+}
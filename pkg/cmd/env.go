@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+)
+
+// Env prints the activation script for the config, suitable for eval "$(yourpm env)".
+// --systemd and --launchd install the environment into the user session
+// instead, for GUI apps and daemons that never start a shell. --output json
+// prints it for editors, json=v1 pinning the version of that JSON, and
+// --watch keeps printing it as it changes.
+func Env(args []string) {
+	flags := flag.NewFlagSet("env", flag.ExitOnError)
+	systemd := flags.Bool("systemd", false, "write the environment to ~/.config/environment.d for the systemd user session")
+	launchd := flags.Bool("launchd", false, "set the environment in the launchd user session with launchctl setenv")
+	asJSON := flags.Bool("json", false, "deprecated, use --output json")
+	output := flags.String("output", "", outputFlagHelp(envInfoVersion))
+	watch := flags.Bool("watch", false, "print the environment as a JSON line whenever it changes")
+	flags.Parse(args)
+	if *asJSON {
+		deprecated([]string{"env --json is deprecated and will be removed, use --output json instead"})
+	}
+	if *output != "" {
+		outputVersion("env", *output, envInfoVersion)
+		*asJSON = true
+	}
+
+	baseDir := yourpmDir()
+	configPath, cfg := loadConfig(baseDir, flags.Args())
+
+	prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
+	applied := loadState(baseDir)
+	activ := activation(cfg, applied)
+
+	switch {
+	case *watch:
+		watchEnv(baseDir, prof, configPath)
+	case *asJSON:
+		out, err := json.MarshalIndent(newEnvInfo(prof, configPath, cfg, applied), "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode environment: %v", err)
+		}
+		fmt.Println(string(out))
+	case *systemd:
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			log.Fatalf("Failed to find the user config dir: %v", err)
+		}
+		path := filepath.Join(configDir, "environment.d", "50-yourpm.conf")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+		}
+		conf, err := prof.EnvironmentD(activ)
+		if err != nil {
+			log.Fatalf("Failed to render environment.d: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(conf), 0644); err != nil {
+			log.Fatalf("Failed to write %s: %v", path, err)
+		}
+		fmt.Printf("%s Wrote %s, it applies from your next login\n", sym.ok, path)
+	case *launchd:
+		env := prof.Environ(activ, nil)
+		for _, kv := range env {
+			key, value, _ := strings.Cut(kv, "=")
+			if err := run([]string{"launchctl", "setenv", key, value}); err != nil {
+				log.Fatalf("Failed to set %s: %v", key, err)
+			}
+		}
+		fmt.Printf("%s Set %d variables in the launchd session; apps started from now on see them\n", sym.ok, len(env))
+		fmt.Println("launchctl setenv doesn't survive a reboot, run this again after logging in")
+	default:
+		fmt.Print(prof.ActivationScript(activ))
+	}
+}
+
+// Deactivate prints a script undoing yourpm env in the current shell, for
+// eval "$(yourpm deactivate)": it takes the profile out of PATH and puts
+// back the variables activation changed
+func Deactivate(args []string) {
+	baseDir := yourpmDir()
+	_, cfg := loadConfig(baseDir, args)
+	prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
+
+	activ := activation(cfg, loadState(baseDir))
+	fmt.Print(prof.DeactivationScript(activ, os.Getenv("PATH"), os.Getenv("_YOURPM_VARS")))
+}
+
+// Exec runs a command with the profile environment applied
+func Exec(args []string) {
+	flags := flag.NewFlagSet("exec", flag.ExitOnError)
+	noColorFlag(flags)
+	configFile := flags.String("config", "", "config file to take the environment from")
+	flags.Parse(args)
+
+	command := flags.Args()
+	if len(command) == 0 {
+		log.Fatalf("Usage: yourpm exec [--config file] -- <command> [args...]")
+	}
+
+	baseDir := yourpmDir()
+	configPath, cfg := loadConfig(baseDir, configArgs(*configFile))
+	runInEnvironment(baseDir, configPath, cfg, command)
+}
+
+// runInEnvironment runs command with the config's environment applied and
+// exits with its exit code if it fails. It warns first if the config isn't
+// the one last switched to, since the packages are still that one's.
+func runInEnvironment(baseDir string, configPath string, cfg *config.Config, command []string) {
+	applied := loadState(baseDir)
+	repairMissing(baseDir, applied)
+	if applied.ConfigHash != "" && configHash(configPath) != applied.ConfigHash {
+		fmt.Fprintf(os.Stderr, "%s Environment is stale, %s changed since the last switch, run yourpm switch\n", sym.warn, configPath)
+	}
+
+	prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
+	code, err := runActivated(prof, activation(cfg, applied), command)
+	if err != nil {
+		log.Fatalf("Failed to run %s: %v", command[0], err)
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// runActivated runs command with the profile's environment and returns its
+// exit code
+func runActivated(prof *profile.Profile, activ profile.Activation, command []string) (int, error) {
+	env := prof.Environ(activ, os.Environ())
+
+	// Resolve the command against the activated PATH rather than ours
+	os.Setenv("PATH", prof.Path(activ, os.Getenv("PATH")))
+
+	c := exec.Command(command[0], command[1:]...)
+	c.Env = env
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	if err := c.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 0, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+	"github.com/crbroughton/pkg-exploration/pkg/sealed"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+)
+
+// envInfoVersion is bumped when a field changes meaning or goes away, so
+// editor extensions can tell which contract they are reading
+const envInfoVersion = 1
+
+// envInfo is what env --output json prints, for editors to configure language
+// servers and tasks against the environment
+type envInfo struct {
+	Version     int               `json:"version"`
+	Environment string            `json:"environment"`
+	Config      string            `json:"config"`
+	AppliedAt   time.Time         `json:"applied_at"`
+	BinDir      string            `json:"bin_dir"`
+	Env         map[string]string `json:"env"`
+	Tools       []toolInfo        `json:"tools"`
+}
+
+type toolInfo struct {
+	Name      string   `json:"name"`
+	Version   string   `json:"version"`
+	Commands  []string `json:"commands"`
+	StorePath string   `json:"store_path"`
+}
+
+// newEnvInfo describes the environment cfg and the applied state make.
+// Env holds every variable the environment sets, PATH included.
+func newEnvInfo(prof *profile.Profile, configPath string, cfg *config.Config, applied *state.State) envInfo {
+	info := envInfo{
+		Version:     envInfoVersion,
+		Environment: cfg.Name,
+		Config:      configPath,
+		AppliedAt:   applied.AppliedAt,
+		BinDir:      prof.BinDir(),
+		Env:         make(map[string]string),
+		Tools:       []toolInfo{},
+	}
+	for _, kv := range prof.Environ(activation(cfg, applied), nil) {
+		key, value, _ := strings.Cut(kv, "=")
+		info.Env[key] = value
+	}
+
+	names := make([]string, 0, len(applied.Packages))
+	for name := range applied.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pkg := applied.Packages[name]
+		info.Tools = append(info.Tools, toolInfo{
+			Name:      name,
+			Version:   pkg.Version,
+			Commands:  pkg.Binaries,
+			StorePath: pkg.StorePath,
+		})
+	}
+	return info
+}
+
+// watchEnv prints the environment as a JSON line now and again whenever a
+// switch or an edit to the config changes it, until interrupted
+func watchEnv(baseDir string, prof *profile.Profile, configPath string) {
+	var last string
+	var lastMod time.Time
+	for ; ; time.Sleep(time.Second) {
+		modified := latestModTime(configPath, statePath(baseDir))
+		if !modified.After(lastMod) {
+			continue
+		}
+		lastMod = modified
+
+		// A config caught mid-edit is skipped rather than ending the stream
+		cfg, err := config.LoadConfig(configPath)
+		if err == nil {
+			err = sealed.Open(cfg, ageIdentity(baseDir))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", sym.warn, err)
+			continue
+		}
+		applied, err := state.Load(statePath(baseDir))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", sym.warn, err)
+			continue
+		}
+		applyHosts(cfg, hostTags(applied))
+
+		line, err := json.Marshal(newEnvInfo(prof, configPath, cfg, applied))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", sym.warn, err)
+			continue
+		}
+		if string(line) != last {
+			fmt.Println(string(line))
+			last = string(line)
+		}
+	}
+}
+
+func latestModTime(paths ...string) time.Time {
+	var latest time.Time
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+)
+
+// Explain reports how a command would be executed in the environment
+func Explain(args []string) {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	configFile := flags.String("config", "", "config file to explain against")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatalf("Usage: yourpm explain [--config file] <command>")
+	}
+	command := flags.Arg(0)
+
+	baseDir := yourpmDir()
+	configPath, cfg := loadConfig(baseDir, configArgs(*configFile))
+	mfst := loadManifest(baseDir, cfg)
+
+	st := store.NewStore(filepath.Join(baseDir, "store"))
+	prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
+
+	fmt.Printf("%s\n", command)
+
+	name, ok := provider(mfst, cfg, command)
+	if !ok {
+		fmt.Printf("  not provided by any package in %s\n", configPath)
+		explainPath(prof, command, false)
+		return
+	}
+
+	version := cfg.Packages[name]
+	fmt.Printf("  package:   %s@%s\n", name, version)
+	fmt.Printf("  manifest:  %s\n", mfst.Source(name))
+	if url, err := mfst.GetURL(name, version); err == nil {
+		fmt.Printf("  source:    %s\n", url)
+	} else {
+		fmt.Printf("  source:    %v\n", err)
+	}
+
+	storePath := st.Path(name, version)
+	if st.Installed(name, version) {
+		fmt.Printf("  store:     %s\n", storePath)
+	} else {
+		fmt.Printf("  store:     %s (not installed)\n", storePath)
+	}
+	fmt.Printf("  binary:    %s\n", filepath.Join(storePath, command))
+
+	link := filepath.Join(prof.BinDir(), command)
+	if target, err := os.Readlink(link); err == nil {
+		fmt.Printf("  link:      %s -> %s\n", link, target)
+	} else if _, err := os.Stat(link); err == nil {
+		fmt.Printf("  link:      %s (%s)\n", link, cfg.Settings.LinkMode)
+	} else {
+		fmt.Printf("  link:      %s (missing, run yourpm switch)\n", link)
+	}
+
+	explainPath(prof, command, true)
+}
+
+// provider finds the config package that exposes the command
+func provider(mfst *manifest.Manifest, cfg *config.Config, command string) (string, bool) {
+	names := make([]string, 0, len(cfg.Packages))
+	for name := range cfg.Packages {
+		if _, err := mfst.GetPackage(name); err == nil {
+			names = append(names, name)
+		}
+	}
+
+	owners, _, err := mfst.BinaryOwners(names, cfg.Settings.Providers)
+	if err != nil {
+		return "", false
+	}
+	name, ok := owners[command]
+	return name, ok
+}
+
+// explainPath reports what the command resolves to on the current PATH
+func explainPath(prof *profile.Profile, command string, provided bool) {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		fmt.Printf("  PATH:      not found\n")
+		return
+	}
+
+	if !provided || filepath.Dir(path) == prof.BinDir() {
+		fmt.Printf("  PATH:      %s\n", path)
+	} else {
+		fmt.Printf("  PATH:      %s (shadows the profile)\n", path)
+	}
+}
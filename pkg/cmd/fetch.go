@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+)
+
+// Fetch downloads the config's artifacts without installing them, by default
+// for this machine into the cache, or for another platform into a dir that
+// can be copied into that machine's ~/.yourpm/cache
+func Fetch(args []string) {
+	flags := flag.NewFlagSet("fetch", flag.ExitOnError)
+	platform := flags.String("platform", "", "platform to fetch for, like linux/arm64, defaults to this one")
+	output := flags.String("o", "", "dir to put the downloads in, defaults to the cache")
+	flags.Parse(args)
+
+	baseDir := yourpmDir()
+	_, cfg := loadConfig(baseDir, flags.Args())
+	mfst := loadManifest(baseDir, cfg)
+
+	target := manifest.Platform()
+	if *platform != "" {
+		target = strings.ReplaceAll(*platform, "/", "-")
+	}
+	dir := *output
+	if dir == "" {
+		dir = filepath.Join(baseDir, "cache")
+	}
+
+	con := newConsole()
+	eng := newEngine(baseDir, mfst, profile.NewProfile(filepath.Join(baseDir, "profiles", "default")))
+	eng.Observer = con
+
+	fetched, err := eng.Fetch(context.Background(), cfg, target, dir)
+	for name := range cfg.Packages {
+		con.flush(name)
+	}
+	var pkgErr *engine.PackageError
+	if errors.As(err, &pkgErr) {
+		log.Fatalf("%s %s: %v", sym.fail, pkgErr.Name, pkgErr.Err)
+	}
+	if err != nil {
+		log.Fatalf("%s %v", sym.fail, err)
+	}
+
+	fmt.Println()
+	for _, f := range fetched {
+		fmt.Printf("%s  %s\n", f.SHA256, f.Path)
+	}
+	fmt.Printf("\n%s Fetched %d packages for %s into %s\n", sym.ok, len(fetched), target, dir)
+}
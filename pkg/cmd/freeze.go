@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+)
+
+// Freeze writes a config pinned to exactly what the last switch applied
+func Freeze(args []string) {
+	flags := flag.NewFlagSet("freeze", flag.ExitOnError)
+	output := flags.String("o", "", "write the config to a file instead of stdout")
+	flags.Parse(args)
+
+	baseDir := yourpmDir()
+	applied := loadState(baseDir)
+	if len(applied.Packages) == 0 {
+		log.Fatalf("Nothing has been applied yet, run yourpm switch first")
+	}
+
+	// Keep env, PATH and settings from the config that was applied, if it's still around
+	frozen := &config.Config{Name: applied.Environment}
+	if cfg, err := config.LoadConfig(applied.Config); err == nil {
+		frozen = cfg
+	}
+
+	frozen.Packages = make(map[string]string, len(applied.Packages))
+	for name, pkg := range applied.Packages {
+		frozen.Packages[name] = pkg.Version
+	}
+
+	if *output != "" {
+		if err := frozen.Save(*output); err != nil {
+			log.Fatalf("Failed to write %s: %v", *output, err)
+		}
+		fmt.Fprintf(os.Stderr, "%s Wrote %s\n", sym.ok, *output)
+		return
+	}
+
+	if err := toml.NewEncoder(os.Stdout).Encode(frozen); err != nil {
+		log.Fatalf("Failed to encode config: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
+	"github.com/crbroughton/pkg-exploration/pkg/events"
+	"github.com/crbroughton/pkg-exploration/pkg/guard"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/snapshot"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+)
+
+// GC deletes store entries beyond the keep_versions most recent versions of
+// each package. The applied version always counts as one of them, packages
+// no longer applied lose every version, and versions in a snapshot or
+// another named environment stay. Entries that never finished installing
+// can't be reused, so they go too unless something still points at them.
+func GC(args []string) {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "show what would be deleted without deleting it")
+	flags.Parse(args)
+
+	baseDir := yourpmDir()
+	applied := loadState(baseDir)
+
+	keep := 1
+	if cfg, err := config.LoadConfig(applied.Config); err == nil {
+		keep = cfg.Settings.KeepVersions
+	}
+
+	// Every package name we know of, so store dirs can be split into name and version
+	known := make(map[string]bool)
+	for name := range applied.Packages {
+		known[name] = true
+	}
+	var envs []*state.State
+	envStates, _ := filepath.Glob(envStatePath(baseDir, "*"))
+	for _, path := range envStates {
+		if env, err := state.Load(path); err == nil {
+			envs = append(envs, env)
+			for name := range env.Packages {
+				known[name] = true
+			}
+		}
+	}
+	mfst, err := manifest.LoadManifestWithOverlays(filepath.Join(baseDir, "manifest.toml"), filepath.Join(baseDir, "manifest.d"))
+	if err == nil {
+		for name := range mfst.Packages {
+			known[name] = true
+		}
+	}
+	names := make([]string, 0, len(known))
+	for name := range known {
+		names = append(names, name)
+	}
+
+	st := store.NewStore(filepath.Join(baseDir, "store"))
+	entries, err := st.Entries(names)
+	if err != nil {
+		log.Fatalf("Failed to read store: %v", err)
+	}
+
+	// Snapshots are restored without downloading, so their versions stay
+	pinned := make(map[string]bool)
+	snapshots, err := snapshot.New(snapshotsDir(baseDir)).List()
+	if err != nil {
+		log.Fatalf("Failed to read snapshots: %v", err)
+	}
+	for _, snap := range snapshots {
+		for name, version := range snap.Packages {
+			pinned[name+"@"+version] = true
+		}
+	}
+	// So are the other environments yourpm use flips to
+	for _, env := range envs {
+		for name, pkg := range env.Packages {
+			pinned[name+"@"+pkg.Version] = true
+		}
+	}
+
+	kept := make(map[string]int)
+	for _, entry := range entries {
+		if pkg, ok := applied.Packages[entry.Name]; ok && pkg.Version == entry.Version {
+			kept[entry.Name]++
+		}
+	}
+
+	var doomed []store.Entry
+	for _, entry := range entries {
+		pkg, active := applied.Packages[entry.Name]
+		if active && pkg.Version == entry.Version || pinned[entry.Name+"@"+entry.Version] {
+			continue
+		}
+		if active && entry.Complete && kept[entry.Name] < keep {
+			kept[entry.Name]++
+			continue
+		}
+		doomed = append(doomed, entry)
+	}
+
+	if !*dryRun && len(doomed) > 0 {
+		if err := recordForUndo(baseDir, "gc", entries, applied, mfst); err != nil {
+			log.Fatalf("Failed to record the store for undo, nothing was deleted: %v", err)
+		}
+	}
+
+	removed := 0
+	var pruned []events.Event
+	for _, entry := range doomed {
+		if !*dryRun {
+			size := store.DiskUsage(entry.Path)
+			if err := st.Remove(entry.Name, entry.Version); err != nil {
+				log.Fatalf("Failed to remove %s@%s: %v", entry.Name, entry.Version, err)
+			}
+			pruned = append(pruned, events.Event{Time: time.Now(), Action: events.Pruned, Package: entry.Name, Version: entry.Version, Bytes: size})
+		}
+		if entry.Complete {
+			fmt.Printf("%s %s@%s\n", sym.removed, entry.Name, entry.Version)
+		} else {
+			fmt.Printf("%s %s@%s (incomplete)\n", sym.removed, entry.Name, entry.Version)
+		}
+		removed++
+	}
+	if err := events.Append(eventsPath(baseDir), pruned); err != nil {
+		warn([]string{fmt.Sprintf("Failed to log changes: %v", err)})
+	}
+
+	swept, freed := sweepLeftovers(baseDir, *dryRun)
+	for _, leftover := range swept {
+		fmt.Printf("%s %s\n", sym.removed, leftover.Path)
+	}
+	if len(swept) > 0 {
+		verb := sym.ok + " Deleted"
+		if *dryRun {
+			verb = "Dry run: would delete"
+		}
+		fmt.Printf("%s %d temp files left by interrupted runs, %s\n", verb, len(swept), engine.FormatBytes(uint64(freed)))
+	}
+
+	switch {
+	case removed == 0 && len(swept) == 0:
+		fmt.Printf("%s Nothing to collect\n", sym.ok)
+	case removed == 0:
+		// Only temp files, already reported
+	case *dryRun:
+		fmt.Printf("Dry run: %d store entries would be deleted\n", removed)
+	default:
+		fmt.Printf("%s Deleted %d store entries, yourpm undo brings them back\n", sym.ok, removed)
+	}
+}
+
+// leftoverAge is how long a temp file must sit untouched before it counts
+// as abandoned rather than belonging to a run still in progress
+const leftoverAge = time.Hour
+
+// sweepLeftovers deletes the temp files and dirs interrupted runs left in
+// the yourpm dirs, unless dryRun, and returns them with their total size
+func sweepLeftovers(baseDir string, dryRun bool) ([]store.Leftover, int64) {
+	var swept []store.Leftover
+	var freed int64
+	for _, dir := range []string{baseDir, filepath.Join(baseDir, "cache"), filepath.Join(baseDir, "store"), filepath.Join(baseDir, "configs")} {
+		leftovers, err := store.Leftovers(dir, leftoverAge)
+		if err != nil {
+			warn([]string{fmt.Sprintf("Failed to look for temp files in %s: %v", dir, err)})
+			continue
+		}
+		for _, leftover := range leftovers {
+			if !dryRun {
+				if err := guard.RemoveAll(baseDir, leftover.Path); err != nil {
+					warn([]string{fmt.Sprintf("Failed to delete %s: %v", leftover.Path, err)})
+					continue
+				}
+			}
+			swept = append(swept, leftover)
+			freed += leftover.Size
+		}
+	}
+	return swept, freed
+}
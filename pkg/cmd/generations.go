@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/crbroughton/pkg-exploration/pkg/generation"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+)
+
+// Version is the yourpm release, set at build time with
+// -ldflags "-X github.com/crbroughton/pkg-exploration/pkg/cmd.Version=..."
+var Version = "dev"
+
+// Generations lists or compares the recorded generations
+func Generations(args []string) {
+	history := generation.NewHistory(generationsDir(yourpmDir()))
+
+	if len(args) == 0 || args[0] == "list" {
+		listGenerations(history)
+		return
+	}
+
+	switch args[0] {
+	case "diff":
+		if len(args) != 3 {
+			log.Fatalf("Usage: yourpm generations diff <N> <M>")
+		}
+		diffGenerations(history, args[1], args[2])
+	default:
+		log.Fatalf("Unknown generations command: %s", args[0])
+	}
+}
+
+func listGenerations(history *generation.History) {
+	generations, err := history.List()
+	if err != nil {
+		log.Fatalf("Failed to read generations: %v", err)
+	}
+	if len(generations) == 0 {
+		fmt.Println("No generations yet, run yourpm switch first")
+		return
+	}
+
+	for _, g := range generations {
+		fmt.Printf("%4d  %s  %-12s %d packages  yourpm %s", g.Number, g.CreatedAt.Local().Format("2006-01-02 15:04"), g.Environment, len(g.Packages), g.YourpmVersion)
+		if g.Message != "" {
+			fmt.Printf("  %q", g.Message)
+		}
+		fmt.Println()
+	}
+}
+
+func diffGenerations(history *generation.History, from string, to string) {
+	a, err := getGeneration(history, from)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	b, err := getGeneration(history, to)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	changes := generation.Diff(a, b)
+	if len(changes) == 0 {
+		fmt.Printf("Generations %d and %d have the same packages\n", a.Number, b.Number)
+		return
+	}
+	for _, change := range changes {
+		switch {
+		case change.From == "":
+			fmt.Printf("+ %s %s\n", change.Name, change.To)
+		case change.To == "":
+			fmt.Printf("- %s %s\n", change.Name, change.From)
+		default:
+			fmt.Printf("~ %s %s %s %s\n", change.Name, change.From, sym.arrow, change.To)
+		}
+	}
+}
+
+func getGeneration(history *generation.History, arg string) (*generation.Generation, error) {
+	number, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("not a generation number: %s", arg)
+	}
+	return history.Get(number)
+}
+
+func generationsDir(baseDir string) string {
+	return filepath.Join(baseDir, "generations")
+}
+
+// configHash is the sha256 of the config file, or "" if it can't be read
+func configHash(configPath string) string {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordGeneration adds applied to the history, unless nothing changed
+// since the latest generation and there is no message to record
+func recordGeneration(baseDir string, configPath string, applied *state.State, message string) {
+	history := generation.NewHistory(generationsDir(baseDir))
+
+	g := generation.Generation{
+		CreatedAt:     applied.AppliedAt,
+		Environment:   applied.Environment,
+		Config:        configPath,
+		YourpmVersion: Version,
+		Message:       message,
+		Packages:      make(map[string]string, len(applied.Packages)),
+	}
+	for name, pkg := range applied.Packages {
+		g.Packages[name] = pkg.Version
+	}
+	g.ConfigHash = configHash(configPath)
+
+	generations, err := history.List()
+	if err != nil {
+		warn([]string{fmt.Sprintf("Failed to read generations: %v", err)})
+		return
+	}
+	if n := len(generations); n > 0 && message == "" {
+		latest := generations[n-1]
+		if latest.ConfigHash == g.ConfigHash && maps.Equal(latest.Packages, g.Packages) {
+			return
+		}
+	}
+
+	if _, err := history.Record(g); err != nil {
+		warn([]string{fmt.Sprintf("Failed to record generation: %v", err)})
+	}
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+)
+
+// tagsFlag is the --tags given to switch or plan, nil when there wasn't one
+var tagsFlag []string
+
+// hostTags are the tags selecting [hosts."tag:..."] config sections: those
+// given with --tags, else $YOURPM_TAGS, else the ones applied was switched
+// with, so later commands see the same config that switch applied
+func hostTags(applied *state.State) []string {
+	if tagsFlag != nil {
+		return tagsFlag
+	}
+	if env, ok := os.LookupEnv("YOURPM_TAGS"); ok {
+		return splitTags(env)
+	}
+	return applied.Tags
+}
+
+func splitTags(list string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// applyHosts merges the config's [hosts] sections for this machine
+func applyHosts(cfg *config.Config, tags []string) []string {
+	hostname, _ := os.Hostname()
+	return cfg.ApplyHosts(hostname, tags)
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+)
+
+// Examples are the starter files bundled into the binary
+type Examples struct {
+	Config   []byte
+	Manifest []byte
+}
+
+const starterManifest = `# Package definitions, see manifest.example.toml for the format
+#
+# [packages.jq]
+# repo = "jqlang/jq"
+# description = "Command-line JSON processor"
+#
+# [packages.jq.binaries]
+# names = ["jq"]
+#
+# [packages.jq.urls]
+# linux-amd64 = "https://github.com/jqlang/jq/releases/download/jq-{version}/jq-linux-amd64"
+`
+
+// Init creates the ~/.yourpm layout, starter files and the shell hook
+func Init(args []string, examples Examples) {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	fromExample := flags.Bool("from-example", false, "start from the bundled example config and manifest")
+	from := flags.String("from", "", "base URL to fetch config.toml and manifest.toml from")
+	force := flags.Bool("force", false, "overwrite existing config and manifest")
+	noHook := flags.Bool("no-hook", false, "don't add the activation hook to the shell rc file")
+	flags.Parse(args)
+
+	baseDir := yourpmDir()
+	prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
+
+	for _, dir := range []string{
+		filepath.Join(baseDir, "cache"),
+		filepath.Join(baseDir, "store"),
+		prof.BinDir(),
+	} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	fmt.Printf("%s Created %s\n", sym.ok, baseDir)
+
+	configData := []byte(starterConfig())
+	manifestData := []byte(starterManifest)
+	switch {
+	case *from != "":
+		configData = fetchTemplate(baseDir, *from, "config.toml")
+		manifestData = fetchTemplate(baseDir, *from, "manifest.toml")
+	case *fromExample:
+		configData = examples.Config
+		manifestData = examples.Manifest
+	}
+
+	writeStarter(filepath.Join(baseDir, "config.toml"), configData, *force)
+	writeStarter(filepath.Join(baseDir, "manifest.toml"), manifestData, *force)
+
+	if !*noHook {
+		installHook(prof)
+	}
+
+	if _, err := exec.LookPath("tar"); err != nil {
+		fmt.Printf("%s tar not found in PATH, .tar.xz packages will fail to install\n", sym.warn)
+	} else {
+		fmt.Printf("%s tar available\n", sym.ok)
+	}
+
+	fmt.Printf("\nNext: edit %s and run yourpm switch\n", filepath.Join(baseDir, "config.toml"))
+}
+
+func starterConfig() string {
+	name, err := os.Hostname()
+	if err != nil {
+		name = "default"
+	}
+	return fmt.Sprintf("name = %q\n\n[packages]\n", name)
+}
+
+func fetchTemplate(baseDir string, base string, file string) []byte {
+	url := strings.TrimSuffix(base, "/") + "/" + file
+	dest := filepath.Join(baseDir, "cache", "init-"+file)
+	os.Remove(dest)
+
+	repo := newRepository(baseDir)
+	if err := repo.DownloadFile(context.Background(), url, dest); err != nil {
+		log.Fatalf("Failed to fetch %s: %v", url, err)
+	}
+	defer os.Remove(dest)
+
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", dest, err)
+	}
+	return data
+}
+
+func writeStarter(path string, data []byte, force bool) {
+	if _, err := os.Stat(path); err == nil && !force {
+		fmt.Printf("%s Kept existing %s\n", sym.info, path)
+		return
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", path, err)
+	}
+	fmt.Printf("%s Wrote %s\n", sym.ok, path)
+}
+
+// installHook sources the activation script from the user's shell rc file
+func installHook(prof *profile.Profile) {
+	homeDir, _ := os.UserHomeDir()
+	shell := filepath.Base(os.Getenv("SHELL"))
+
+	var rcFile string
+	switch shell {
+	case "bash":
+		rcFile = filepath.Join(homeDir, ".bashrc")
+	case "zsh":
+		rcFile = filepath.Join(homeDir, ".zshrc")
+	default:
+		rcFile = filepath.Join(homeDir, ".profile")
+	}
+
+	hook := fmt.Sprintf("[ -f \"%s\" ] && . \"%s\"", prof.ActivationPath(), prof.ActivationPath())
+
+	existing, _ := os.ReadFile(rcFile)
+	if bytes.Contains(existing, []byte(prof.ActivationPath())) {
+		fmt.Printf("%s Shell hook already in %s\n", sym.info, rcFile)
+		return
+	}
+
+	f, err := os.OpenFile(rcFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", rcFile, err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "\n# Added by yourpm init\n%s\n", hook); err != nil {
+		log.Fatalf("Failed to write %s: %v", rcFile, err)
+	}
+	fmt.Printf("%s Added shell hook to %s\n", sym.ok, rcFile)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/lint"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+)
+
+// Lint flags risky patterns in a config and its manifest entries, each with
+// a rule ID. --fix pins unpinned versions, to the applied version or else the
+// latest release. It exits 1 while anything is left to fix.
+func Lint(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "fix what can be fixed automatically")
+	var ignore stringsFlag
+	flags.Var(&ignore, "ignore", "rule ID to skip (repeatable)")
+	flags.Parse(args)
+
+	baseDir := yourpmDir()
+	configPath := configPathFrom(baseDir, flags.Args())
+
+	// Read without decrypting, sealed values are what lint wants to see
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config from %s: %v", configPath, err)
+	}
+	mfst, err := manifest.LoadManifestWithOverlays(filepath.Join(baseDir, "manifest.toml"), filepath.Join(baseDir, "manifest.d"))
+	if err != nil {
+		log.Fatalf("Failed to load manifest: %v", err)
+	}
+	warn(cfg.Warnings)
+	warn(mfst.Warnings)
+
+	skip := ignore.set()
+	remaining := 0
+	for _, finding := range lint.Check(cfg, configPath, mfst) {
+		if skip[finding.Rule] {
+			continue
+		}
+		if *fix && finding.Fixable {
+			err := fixFinding(baseDir, cfg, configPath, mfst, finding)
+			if err == nil {
+				continue
+			}
+			warn([]string{fmt.Sprintf("Couldn't fix %s %s: %v", finding.Rule, finding.Key, err)})
+		}
+		fmt.Printf("%s %s\n", sym.warn, finding)
+		remaining++
+	}
+
+	if remaining > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("%s No problems found\n", sym.ok)
+}
+
+// fixFinding pins an unpinned version, the only rule with a fix so far
+func fixFinding(baseDir string, cfg *config.Config, configPath string, mfst *manifest.Manifest, finding lint.Finding) error {
+	if finding.Rule != lint.RuleUnpinnedVersion {
+		return fmt.Errorf("no fix for %s", finding.Rule)
+	}
+	name := finding.Key[len("packages."):]
+
+	pinned := ""
+	if applied := loadState(baseDir); applied.Config == configPath {
+		pinned = applied.Packages[name].Version
+	}
+	if pinned == "" || pinned == cfg.Packages[name] {
+		pkgDef, err := mfst.GetPackage(name)
+		if err != nil {
+			return err
+		}
+		if pkgDef.Repo == "" {
+			return fmt.Errorf("not applied and no repo to find the latest release in")
+		}
+		tag, err := newRepository(baseDir).LatestReleaseFrom(context.Background(), pkgDef.Source, pkgDef.Host, pkgDef.Repo)
+		if err != nil {
+			return err
+		}
+		pinned = pkgDef.TagVersion(tag)
+	}
+
+	if err := config.SetPackageVersion(configPath, name, pinned); err != nil {
+		return err
+	}
+	fmt.Printf("%s Pinned %s to %s (%s)\n", sym.ok, name, pinned, finding.Rule)
+	return nil
+}
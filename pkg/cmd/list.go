@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+)
+
+// List shows the packages applied by the last switch
+func List(args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	showProvenance := flags.Bool("provenance", false, "show where every linked command came from")
+	flags.Parse(args)
+
+	baseDir := yourpmDir()
+	applied := loadState(baseDir)
+	if len(applied.Packages) == 0 {
+		fmt.Println("Nothing installed yet, run yourpm switch")
+		return
+	}
+
+	fmt.Printf("Environment '%s' from %s\n\n", applied.Environment, applied.Config)
+
+	names := make([]string, 0, len(applied.Packages))
+	for name := range applied.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pkg := applied.Packages[name]
+		fmt.Printf("%s %s\n", name, pkg.Version)
+		fmt.Printf("  commands:   %s\n", strings.Join(pkg.Binaries, ", "))
+		if !*showProvenance {
+			continue
+		}
+		fmt.Printf("  url:        %s\n", pkg.URL)
+		fmt.Printf("  sha256:     %s\n", pkg.SHA256)
+		fmt.Printf("  downloaded: %s\n", pkg.DownloadedAt.Format("2006-01-02 15:04:05"))
+		fmt.Printf("  manifest:   %s\n", pkg.Manifest)
+	}
+
+	if *showProvenance {
+		prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
+		listUnrecognised(applied, prof)
+	}
+}
+
+// listUnrecognised flags anything in the profile bin dir that no applied package provides
+func listUnrecognised(applied *state.State, prof *profile.Profile) {
+	entries, err := os.ReadDir(prof.BinDir())
+	if err != nil {
+		return
+	}
+
+	var unknown []string
+	for _, entry := range entries {
+		if _, ok := applied.Owner(entry.Name()); !ok {
+			unknown = append(unknown, entry.Name())
+		}
+	}
+	if len(unknown) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s Not installed by yourpm, found in %s:\n", sym.warn, prof.BinDir())
+	for _, name := range unknown {
+		fmt.Printf("  %s\n", name)
+	}
+}
@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/repository"
+	"github.com/crbroughton/pkg-exploration/pkg/version"
+)
+
+const manifestUsage = "Usage: yourpm manifest check [--config file] [--jobs N] [--rate N] | outdated [--config file] [--patch]"
+
+// Manifest holds tools for whoever maintains the manifest
+func Manifest(args []string) {
+	if len(args) == 0 {
+		log.Fatalf(manifestUsage)
+	}
+
+	switch args[0] {
+	case "check":
+		checkManifest(args[1:])
+	case "outdated":
+		manifestOutdated(args[1:])
+	default:
+		log.Fatalf("Unknown manifest command: %s\n%s", args[0], manifestUsage)
+	}
+}
+
+// urlCheck is one package URL to HEAD, and what came back
+type urlCheck struct {
+	Name     string
+	Version  string
+	Platform string
+	URL      string
+	Probe    repository.Probe
+	Err      error
+}
+
+// checkManifest HEADs every package's URL on every platform it lists, at
+// the version the config pins or otherwise its latest release, to catch
+// upstream renames before a switch hits the 404
+func checkManifest(args []string) {
+	flags := flag.NewFlagSet("manifest check", flag.ExitOnError)
+	configFile := flags.String("config", "", "config whose versions to check, defaults to the one last switched to")
+	jobs := flags.Int("jobs", 4, "requests to have in flight at once")
+	rate := flags.Float64("rate", 10, "most requests to start per second")
+	flags.Parse(args)
+	if *jobs < 1 || *rate <= 0 {
+		log.Fatalf("--jobs and --rate must be positive")
+	}
+
+	baseDir := yourpmDir()
+	if *configFile == "" {
+		*configFile = loadState(baseDir).Config
+	}
+	_, cfg := loadConfig(baseDir, configArgs(*configFile))
+	mfst := loadManifest(baseDir, cfg)
+	repo := newRepository(baseDir)
+	ctx := context.Background()
+
+	names := make([]string, 0, len(mfst.Packages))
+	for name := range mfst.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var checks []*urlCheck
+	for _, name := range names {
+		pkgDef := mfst.Packages[name]
+		want := cfg.Packages[name]
+		if want == "" {
+			if pkgDef.Repo == "" {
+				warn([]string{fmt.Sprintf("Skipping %s: not in the config and no repo to find its latest release", name)})
+				continue
+			}
+			tag, err := repo.LatestReleaseFrom(ctx, pkgDef.Source, pkgDef.Host, pkgDef.Repo)
+			if err != nil {
+				warn([]string{fmt.Sprintf("Skipping %s: %v", name, err)})
+				continue
+			}
+			want = pkgDef.TagVersion(tag)
+		}
+
+		for _, platform := range pkgDef.Platforms() {
+			url, _ := engine.ResolveURL(ctx, repo, mfst, name, want, platform)
+			checks = append(checks, &urlCheck{Name: name, Version: want, Platform: platform, URL: url})
+		}
+	}
+
+	fmt.Printf("Checking %d URLs across %d packages...\n", len(checks), len(names))
+	probeAll(ctx, repo, checks, *jobs, *rate)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	dead, moved := 0, 0
+	for _, c := range checks {
+		switch {
+		case c.Err != nil:
+			dead++
+			fmt.Fprintf(w, "%s\t%s %s\t%s\t%v\n", sym.fail, c.Name, c.Version, c.Platform, c.Err)
+		case c.Probe.Status >= 400:
+			dead++
+			fmt.Fprintf(w, "%s\t%s %s\t%s\tHTTP %d %s\n", sym.fail, c.Name, c.Version, c.Platform, c.Probe.Status, c.URL)
+		case c.Probe.MovedTo != "":
+			moved++
+			fmt.Fprintf(w, "%s\t%s %s\t%s\tmoved to %s\n", sym.warn, c.Name, c.Version, c.Platform, c.Probe.MovedTo)
+		}
+	}
+	w.Flush()
+
+	missing := missingPlatforms(mfst, names)
+	for _, name := range names {
+		if len(missing[name]) > 0 {
+			fmt.Printf("%s %s has no URL for %s\n", sym.warn, name, strings.Join(missing[name], ", "))
+		}
+	}
+
+	fmt.Printf("\n%d ok, %d dead, %d moved, %d packages missing platforms\n", len(checks)-dead-moved, dead, moved, len(missing))
+	if dead > 0 {
+		os.Exit(1)
+	}
+}
+
+// probeAll runs the checks with at most jobs in flight, starting no more
+// than rate a second so a big manifest doesn't trip a forge's rate limit
+func probeAll(ctx context.Context, repo *repository.HttpRepository, checks []*urlCheck, jobs int, rate float64) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+
+	queue := make(chan *urlCheck)
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range queue {
+				c.Probe, c.Err = repo.ProbeURL(ctx, c.URL)
+			}
+		}()
+	}
+
+	for i, c := range checks {
+		if i > 0 {
+			<-ticker.C
+		}
+		queue <- c
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// missingPlatforms lists, per package, the platforms some other package in
+// the manifest has a download for but it doesn't
+func missingPlatforms(mfst *manifest.Manifest, names []string) map[string][]string {
+	known := make(map[string]bool)
+	for _, name := range names {
+		pkgDef := mfst.Packages[name]
+		for _, platform := range pkgDef.Platforms() {
+			known[platform] = true
+		}
+	}
+
+	missing := make(map[string][]string)
+	for _, name := range names {
+		pkgDef := mfst.Packages[name]
+		has := pkgDef.Platforms()
+		var lacking []string
+		for platform := range known {
+			if !slices.Contains(has, platform) {
+				lacking = append(lacking, platform)
+			}
+		}
+		if len(lacking) > 0 {
+			sort.Strings(lacking)
+			missing[name] = lacking
+		}
+	}
+	return missing
+}
+
+// hardcodedVersion finds a version written into a URL in place of {version}
+var hardcodedVersion = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// manifestOutdated compares every package's latest release with the version
+// the config pins and any version hardcoded in its URLs. With --patch it
+// prints a patch to the manifest files bumping the hardcoded versions; the
+// config's pins are left to yourpm upgrade.
+func manifestOutdated(args []string) {
+	flags := flag.NewFlagSet("manifest outdated", flag.ExitOnError)
+	configFile := flags.String("config", "", "config whose pins to compare, defaults to the one last switched to")
+	patch := flags.Bool("patch", false, "print a patch bumping versions hardcoded in the manifest, apply it from ~/.yourpm with patch -p1")
+	flags.Parse(args)
+
+	baseDir := yourpmDir()
+	if *configFile == "" {
+		*configFile = loadState(baseDir).Config
+	}
+	_, cfg := loadConfig(baseDir, configArgs(*configFile))
+	mfst := loadManifest(baseDir, cfg)
+	repo := newRepository(baseDir)
+	ctx := context.Background()
+
+	names := make([]string, 0, len(mfst.Packages))
+	for name := range mfst.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// replacements maps each manifest file to the URLs to rewrite in it
+	replacements := make(map[string]map[string]string)
+	var rows [][4]string
+	for _, name := range names {
+		pkgDef := mfst.Packages[name]
+		if pkgDef.Repo == "" {
+			continue
+		}
+		tag, err := repo.LatestReleaseFrom(ctx, pkgDef.Source, pkgDef.Host, pkgDef.Repo)
+		if err != nil {
+			warn([]string{fmt.Sprintf("Failed to check %s: %v", name, err)})
+			continue
+		}
+		latest := pkgDef.TagVersion(tag)
+
+		listed := ""
+		for _, platform := range pkgDef.Platforms() {
+			url := pkgDef.URLs[platform]
+			if strings.Contains(url, "{version}") {
+				continue
+			}
+			found := hardcodedVersion.FindString(urlPath(url))
+			if found == "" || version.Compare(latest, found) <= 0 {
+				continue
+			}
+			listed = found
+			file := mfst.Source(name)
+			if replacements[file] == nil {
+				replacements[file] = make(map[string]string)
+			}
+			replacements[file][url] = strings.ReplaceAll(url, found, latest)
+		}
+
+		pinned := cfg.Packages[name]
+		pinOutdated := pinned != "" && version.Compare(latest, pinned) > 0
+		if pinOutdated || listed != "" {
+			rows = append(rows, [4]string{name, orDash(pinned), orDash(listed), latest})
+		}
+	}
+
+	if !*patch {
+		if len(rows) == 0 {
+			fmt.Printf("%s Everything is up to date\n", sym.ok)
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "PACKAGE\tPINNED\tMANIFEST\tLATEST\n")
+		for _, row := range rows {
+			fmt.Fprintf(w, "%s\n", strings.Join(row[:], "\t"))
+		}
+		w.Flush()
+		return
+	}
+	files := make([]string, 0, len(replacements))
+	for file := range replacements {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		diff, err := rewritePatch(baseDir, file, replacements[file])
+		if err != nil {
+			log.Fatalf("Failed to patch %s: %v", file, err)
+		}
+		fmt.Print(diff)
+	}
+}
+
+// urlPath is the path part of a URL, so a version isn't read from an IP address
+func urlPath(rawURL string) string {
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return parsed.Path
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// rewritePatch renders a unified diff of file with each old URL replaced by
+// its new one, named relative to baseDir for patch -p1
+func rewritePatch(baseDir string, file string, urls map[string]string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	before := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	after := make([]string, len(before))
+	for i, line := range before {
+		after[i] = line
+		for old, updated := range urls {
+			after[i] = strings.ReplaceAll(after[i], strconv.Quote(old), strconv.Quote(updated))
+			after[i] = strings.ReplaceAll(after[i], "'"+old+"'", "'"+updated+"'")
+		}
+	}
+
+	rel, err := filepath.Rel(baseDir, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = file
+	}
+	return unifiedDiff(filepath.ToSlash(rel), before, after), nil
+}
+
+// unifiedDiff diffs two versions of a file whose lines were only changed in
+// place, with three lines of context around each change
+func unifiedDiff(name string, before []string, after []string) string {
+	const context = 3
+
+	var changed []int
+	for i := range before {
+		if before[i] != after[i] {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", name, name)
+	for i := 0; i < len(changed); {
+		// Hunks whose context would touch are merged
+		j := i
+		for j+1 < len(changed) && changed[j+1]-changed[j] <= 2*context {
+			j++
+		}
+		start := max(changed[i]-context, 0)
+		end := min(changed[j]+context+1, len(before))
+
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", start+1, end-start, start+1, end-start)
+		for line := start; line < end; line++ {
+			if before[line] == after[line] {
+				fmt.Fprintf(&b, " %s\n", before[line])
+				continue
+			}
+			fmt.Fprintf(&b, "-%s\n+%s\n", before[line], after[line])
+		}
+		i = j + 1
+	}
+	return b.String()
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// templateFiles are the fragments a template repository may provide
+var templateFiles = []string{"config.toml", "manifest.toml"}
+
+// New instantiates a project environment from a template repository
+func New(args []string) {
+	flags := flag.NewFlagSet("new", flag.ExitOnError)
+	template := flags.String("template", "", "template repository, e.g. github.com/org/frontend-env, or a local directory")
+	name := flags.String("name", "", "project name, defaults to the directory name")
+	force := flags.Bool("force", false, "overwrite existing files in the project directory")
+	flags.Parse(args)
+
+	if *template == "" {
+		log.Fatalf("Usage: yourpm new --template <repo> [--name project] [dir]")
+	}
+
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		log.Fatalf("Failed to resolve %s: %v", dir, err)
+	}
+	if *name == "" {
+		*name = filepath.Base(dir)
+	}
+
+	// Read everything up front so the clone is gone before anything can fail
+	source, cleanup := fetchTemplateRepo(*template)
+	fragments, err := readTemplate(source)
+	cleanup()
+	if err != nil {
+		log.Fatalf("Failed to read template: %v", err)
+	}
+	if len(fragments) == 0 {
+		log.Fatalf("Template %s has none of: %s", *template, strings.Join(templateFiles, ", "))
+	}
+
+	homeDir, _ := os.UserHomeDir()
+	replacer := strings.NewReplacer(
+		"{project}", *name,
+		"{dir}", dir,
+		"{home}", homeDir,
+	)
+
+	// The config lives with the project while manifest fragments become a
+	// drop-in, so the project's packages resolve without editing the main manifest
+	targets := map[string]string{
+		"config.toml":   filepath.Join(dir, "config.toml"),
+		"manifest.toml": filepath.Join(yourpmDir(), "manifest.d", *name+".toml"),
+	}
+
+	for _, file := range templateFiles {
+		data, ok := fragments[file]
+		if !ok {
+			continue
+		}
+
+		target := targets[file]
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			log.Fatalf("Failed to create %s: %v", filepath.Dir(target), err)
+		}
+		writeStarter(target, []byte(replacer.Replace(string(data))), *force)
+	}
+
+	fmt.Printf("\nNext: yourpm switch %s\n", filepath.Join(dir, "config.toml"))
+}
+
+// readTemplate reads the template files source provides, by name
+func readTemplate(source string) (map[string][]byte, error) {
+	fragments := make(map[string][]byte)
+	for _, file := range templateFiles {
+		data, err := os.ReadFile(filepath.Join(source, file))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		fragments[file] = data
+	}
+	return fragments, nil
+}
+
+// fetchTemplateRepo returns a directory holding the template, cloning it when remote
+func fetchTemplateRepo(template string) (string, func()) {
+	if info, err := os.Stat(template); err == nil && info.IsDir() {
+		return template, func() {}
+	}
+
+	url := template
+	if !strings.Contains(url, "://") && !strings.HasPrefix(url, "git@") {
+		url = "https://" + url
+	}
+
+	tempDir, err := os.MkdirTemp("", "yourpm-template-")
+	if err != nil {
+		log.Fatalf("Failed to create temp dir: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	clone := exec.Command("git", "clone", "--quiet", "--depth", "1", url, tempDir)
+	clone.Stderr = os.Stderr
+	if err := clone.Run(); err != nil {
+		cleanup()
+		log.Fatalf("Failed to clone template %s: %v", url, err)
+	}
+	fmt.Printf("%s Fetched template %s\n", sym.ok, url)
+
+	return tempDir, cleanup
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/crbroughton/pkg-exploration/pkg/version"
+)
+
+// update is a package with a newer release than the config pins
+type update struct {
+	Name    string `json:"name"`
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+}
+
+// Outdated lists packages in the config with newer releases upstream,
+// without changing anything. It can also raise a desktop notification or
+// post the list to a webhook, for use from a scheduled refresh.
+func Outdated(args []string) {
+	flags := flag.NewFlagSet("outdated", flag.ExitOnError)
+	configFile := flags.String("config", "", "config to check, defaults to the one last switched to")
+	notify := flags.Bool("notify", false, "show a desktop notification when anything is outdated")
+	webhook := flags.String("webhook", "", "POST the outdated packages as JSON to this URL")
+	flags.Parse(args)
+
+	baseDir := yourpmDir()
+	if *configFile == "" {
+		*configFile = loadState(baseDir).Config
+	}
+	_, cfg := loadConfig(baseDir, configArgs(*configFile))
+	mfst := loadManifest(baseDir, cfg)
+	repo := newRepository(baseDir)
+	ctx := context.Background()
+
+	names := make([]string, 0, len(cfg.Packages))
+	for name := range cfg.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var updates []update
+	for _, name := range names {
+		pkgDef, err := mfst.GetPackage(name)
+		if err != nil {
+			warn([]string{err.Error()})
+			continue
+		}
+		if pkgDef.Repo == "" {
+			continue
+		}
+
+		tag, err := repo.LatestReleaseFrom(ctx, pkgDef.Source, pkgDef.Host, pkgDef.Repo)
+		if err != nil {
+			warn([]string{fmt.Sprintf("Failed to check %s: %v", name, err)})
+			continue
+		}
+		latest := pkgDef.TagVersion(tag)
+		if version.Compare(latest, cfg.Packages[name]) > 0 {
+			updates = append(updates, update{Name: name, Current: cfg.Packages[name], Latest: latest})
+		}
+	}
+
+	if len(updates) == 0 {
+		fmt.Printf("%s Everything is up to date\n", sym.ok)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "PACKAGE\tCURRENT\tLATEST\n")
+	for _, u := range updates {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", u.Name, u.Current, u.Latest)
+	}
+	w.Flush()
+
+	if *notify {
+		if err := desktopNotify(updates); err != nil {
+			warn([]string{fmt.Sprintf("Failed to notify: %v", err)})
+		}
+	}
+	if *webhook != "" {
+		if err := postWebhook(ctx, repo.Client(), *webhook, cfg.Name, updates); err != nil {
+			warn([]string{fmt.Sprintf("Failed to post to webhook: %v", err)})
+		}
+	}
+}
+
+func summary(updates []update) string {
+	parts := make([]string, len(updates))
+	for i, u := range updates {
+		parts[i] = fmt.Sprintf("%s %s → %s", u.Name, u.Current, u.Latest)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func desktopNotify(updates []update) error {
+	title := fmt.Sprintf("yourpm: %d updates available", len(updates))
+	switch runtime.GOOS {
+	case "linux":
+		return exec.Command("notify-send", title, summary(updates)).Run()
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", summary(updates), title)
+		return exec.Command("osascript", "-e", script).Run()
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+}
+
+func postWebhook(ctx context.Context, client *http.Client, url string, environment string, updates []update) error {
+	body, err := json.Marshal(map[string]any{
+		"environment": environment,
+		"outdated":    updates,
+		"text":        fmt.Sprintf("yourpm environment %s has updates: %s", environment, summary(updates)),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+)
+
+// symbols are the markers that start status lines
+type symbols struct {
+	ok      string
+	fail    string
+	warn    string
+	pkg     string
+	removed string
+	info    string
+	arrow   string
+	// spinner holds the frames of the download spinner, one rune each
+	spinner string
+}
+
+var styles = map[string]symbols{
+	config.OutputFancy: {ok: "✓", fail: "✗", warn: "⚠", pkg: "📦", removed: "🗑", info: "•", arrow: "→", spinner: "⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏"},
+	config.OutputPlain: {ok: "✓", fail: "✗", warn: "!", pkg: "==>", removed: "-", info: "*", arrow: "→", spinner: `|/-\`},
+	config.OutputASCII: {ok: "ok", fail: "x", warn: "!", pkg: "==>", removed: "-", info: "*", arrow: "->", spinner: `|/-\`},
+}
+
+// ANSI colors for the status markers
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorDim    = "\x1b[2m"
+)
+
+var (
+	// outputStyle starts from YOURPM_OUTPUT or what the terminal looks able
+	// to show, and a config's output setting can change it
+	outputStyle = detectOutputStyle()
+	color       = colorEnabled()
+
+	// sym is the symbol set in use, colored when color is on
+	sym = currentSymbols()
+)
+
+func currentSymbols() symbols {
+	s := styles[outputStyle]
+	if !color {
+		return s
+	}
+	s.ok = colorGreen + s.ok + colorReset
+	s.fail = colorRed + s.fail + colorReset
+	s.warn = colorYellow + s.warn + colorReset
+	s.pkg = colorBold + s.pkg + colorReset
+	s.removed = colorDim + s.removed + colorReset
+	return s
+}
+
+// detectOutputStyle picks ascii for non-UTF-8 locales and plain when stdout
+// isn't a terminal, such as in CI logs, unless YOURPM_OUTPUT says otherwise
+func detectOutputStyle() string {
+	if style := os.Getenv("YOURPM_OUTPUT"); styles[style] != (symbols{}) {
+		return style
+	}
+	if !utf8Locale() {
+		return config.OutputASCII
+	}
+	if !isTerminal(os.Stdout) {
+		return config.OutputPlain
+	}
+	return config.OutputFancy
+}
+
+// setOutputStyle applies a config's output setting; YOURPM_OUTPUT still wins
+func setOutputStyle(style string) {
+	if style == "" || os.Getenv("YOURPM_OUTPUT") != "" {
+		return
+	}
+	outputStyle = style
+	sym = currentSymbols()
+}
+
+// DisableColor turns color off, for --no-color
+func DisableColor() {
+	color = false
+	sym = currentSymbols()
+}
+
+// noColorFlag registers --no-color on commands that pass their remaining
+// arguments on to another command, where it can't be taken out up front
+func noColorFlag(flags *flag.FlagSet) {
+	flags.BoolFunc("no-color", "disable color output", func(string) error {
+		DisableColor()
+		return nil
+	})
+}
+
+// colorEnabled follows NO_COLOR, CLICOLOR_FORCE and CLICOLOR
+// (https://no-color.org, https://bixense.com/clicolors), and otherwise colors
+// only terminals outside CI
+func colorEnabled() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+	if os.Getenv("CLICOLOR") == "0" || os.Getenv("CI") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// animate reports whether a spinner can redraw itself on stderr
+func animate() bool {
+	return isTerminal(os.Stderr) && os.Getenv("CI") == "" && os.Getenv("TERM") != "dumb"
+}
+
+// utf8Locale follows the POSIX precedence of LC_ALL, LC_CTYPE and LANG.
+// With none set, assume UTF-8 as every modern terminal uses it.
+func utf8Locale() bool {
+	for _, key := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if value := os.Getenv(key); value != "" {
+			value = strings.ToLower(value)
+			return strings.Contains(value, "utf-8") || strings.Contains(value, "utf8")
+		}
+	}
+	return true
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+)
+
+var planSymbols = map[engine.Action]string{
+	engine.ActionInstall: "+",
+	engine.ActionChange:  "~",
+	engine.ActionKeep:    "=",
+	engine.ActionRemove:  "-",
+}
+
+// Plan works out what switching to a config would do and saves it as a plan
+// file, for CI or a reviewer to check before yourpm apply carries it out
+func Plan(args []string) {
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	output := flags.String("o", "", "file to save the plan to, as JSON")
+	tags := flags.String("tags", "", "comma separated tags selecting the config's [hosts.\"tag:...\"] sections")
+	flags.Parse(args)
+
+	baseDir := yourpmDir()
+	if *tags != "" {
+		tagsFlag = splitTags(*tags)
+	}
+	configPath, cfg := loadConfig(baseDir, flags.Args())
+	mfst := loadManifest(baseDir, cfg)
+	eng := newEngine(baseDir, mfst, profile.NewProfile(filepath.Join(baseDir, "profiles", "default")))
+
+	plan, err := eng.Plan(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("%s %v", sym.fail, err)
+	}
+	plan.Config = configPath
+	plan.ConfigSHA256 = configHash(configPath)
+	printPlan(plan)
+
+	if *output == "" {
+		return
+	}
+	if err := engine.WritePlan(*output, plan); err != nil {
+		log.Fatalf("Failed to save plan: %v", err)
+	}
+	fmt.Printf("\n%s Saved plan to %s, carry it out with yourpm apply %s\n", sym.ok, *output, *output)
+}
+
+// Apply carries out a plan file, refusing if the config, manifest or
+// applied environment changed since it was made
+func Apply(args []string) {
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	message := flags.String("m", "", "note recorded with the generation this creates")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatalf("Usage: yourpm apply [-m message] <plan-file>")
+	}
+
+	plan, err := engine.ReadPlan(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to read plan: %v", err)
+	}
+
+	baseDir := yourpmDir()
+	if configHash(plan.Config) != plan.ConfigSHA256 {
+		log.Fatalf("%s %s changed since the plan was made, run yourpm plan again", sym.fail, plan.Config)
+	}
+	configPath, cfg := loadConfig(baseDir, []string{plan.Config})
+	mfst := loadManifest(baseDir, cfg)
+	prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
+	if cfg.Settings.Strictness == config.StrictnessStrict {
+		checkShadowing(mfst, cfg, prof)
+	}
+
+	eng := newEngine(baseDir, mfst, prof)
+	ctx := context.Background()
+	if err := eng.CheckPlan(ctx, cfg, plan); err != nil {
+		log.Fatalf("%s %v, run yourpm plan again", sym.fail, err)
+	}
+
+	applied := applyOrExit(ctx, eng, configPath, cfg)
+	recordGeneration(baseDir, configPath, applied, *message)
+
+	fmt.Printf("%s Environment '%s' is now active\n", sym.ok, cfg.Name)
+}
+
+// printPlan shows a plan with download and installed sizes
+func printPlan(plan *engine.Plan) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\tPACKAGE\tVERSION\tDOWNLOAD\tINSTALLED\n")
+	unknown := false
+	for _, entry := range plan.Entries {
+		version := entry.Version
+		switch entry.Action {
+		case engine.ActionChange:
+			version = entry.PreviousVersion + " " + sym.arrow + " " + entry.Version
+		case engine.ActionRemove:
+			version = entry.PreviousVersion
+			fmt.Fprintf(w, "%s\t%s\t%s\t\t\n", planSymbols[entry.Action], entry.Name, version)
+			continue
+		}
+		if entry.DownloadSize < 0 {
+			unknown = true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", planSymbols[entry.Action], entry.Name, version,
+			planSize(entry.DownloadSize, "cached"), planSize(entry.InstalledSize, "?"))
+	}
+	download, installed := plan.Totals()
+	fmt.Fprintf(w, "\tTotal\t\t%s\t%s\n", engine.FormatBytes(uint64(download)), engine.FormatBytes(uint64(installed)))
+	w.Flush()
+
+	if unknown {
+		fmt.Println("\nSome sizes are unknown; the server did not report a Content-Length.")
+	}
+}
+
+func planSize(size int64, zero string) string {
+	switch {
+	case size < 0:
+		return "?"
+	case size == 0:
+		return zero
+	default:
+		return engine.FormatBytes(uint64(size))
+	}
+}
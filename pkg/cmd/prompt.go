@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+)
+
+// Prompt prints the active environment for a shell prompt, with a * when the
+// config asks for other packages than were applied. It runs on every prompt, so it
+// only reads the state file unless the config is newer than the last switch,
+// and prints nothing rather than failing.
+func Prompt(args []string) {
+	flags := flag.NewFlagSet("prompt", flag.ExitOnError)
+	dirtyMark := flags.String("dirty", "*", "appended when the config differs from what was applied")
+	flags.Parse(args)
+
+	applied, err := state.Load(statePath(yourpmDir()))
+	if err != nil || applied.Environment == "" {
+		return
+	}
+
+	mark := ""
+	if configChanged(applied) {
+		mark = *dirtyMark
+	}
+	fmt.Printf("%s%s\n", applied.Environment, mark)
+}
+
+// configChanged reports whether the applied config now asks for different packages
+func configChanged(applied *state.State) bool {
+	info, err := os.Stat(applied.Config)
+	if err != nil {
+		return true
+	}
+	if !info.ModTime().After(applied.AppliedAt) {
+		return false
+	}
+
+	cfg, err := config.LoadConfig(applied.Config)
+	if err != nil {
+		return true
+	}
+	applyHosts(cfg, hostTags(applied))
+	return cfg.Name != applied.Environment || !applied.Matches(cfg.Packages)
+}
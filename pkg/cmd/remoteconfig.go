@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// remoteConfig reports whether a config argument is "-" for stdin or a URL
+// rather than a local path
+func remoteConfig(arg string) bool {
+	return arg == "-" || strings.HasPrefix(arg, "https://") || strings.HasPrefix(arg, "http://")
+}
+
+// materializeConfig reads a config from stdin or a URL, verifies it against
+// the expected sha256 and minisign public key when given, and keeps it under
+// ~/.yourpm/configs named by its hash, so the applied state can point at it
+// like any other config file. Plain http is refused, since a config decides
+// what gets downloaded and run.
+func materializeConfig(baseDir string, source string, expectSHA256 string, minisignKey string) string {
+	if strings.HasPrefix(source, "http://") {
+		log.Fatalf("%s Refusing to fetch a config over plain http, use https: %s", sym.fail, source)
+	}
+	if source != "-" && expectSHA256 == "" && minisignKey == "" {
+		fmt.Fprintf(os.Stderr, "%s WARNING: %s is not verified, anyone who can change it controls what this installs and runs\n", sym.warn, source)
+		fmt.Fprintf(os.Stderr, "%s Pin it with --sha256 or check its signature with --minisign-key\n", sym.warn)
+	}
+
+	dir := filepath.Join(baseDir, "configs")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatalf("Failed to create %s: %v", dir, err)
+	}
+
+	var data []byte
+	var err error
+	label := source
+	if source == "-" {
+		label = "stdin"
+		if minisignKey != "" {
+			log.Fatalf("--minisign-key needs a config URL to fetch the signature from")
+		}
+		data, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("Failed to read config from stdin: %v", err)
+		}
+	} else {
+		data = fetchRemote(baseDir, source)
+	}
+
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	if expectSHA256 != "" && !strings.EqualFold(expectSHA256, digest) {
+		log.Fatalf("%s Config from %s has sha256 %s, expected %s", sym.fail, label, digest, expectSHA256)
+	}
+
+	// Written aside until verified, the state may point at an earlier copy
+	path := filepath.Join(dir, digest[:16]+".toml")
+	unverified := path + ".unverified"
+	if err := os.WriteFile(unverified, data, 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", unverified, err)
+	}
+	defer os.Remove(unverified)
+
+	if minisignKey != "" {
+		signature := path + ".minisig"
+		if err := os.WriteFile(signature, fetchRemote(baseDir, source+".minisig"), 0644); err != nil {
+			log.Fatalf("Failed to write %s: %v", signature, err)
+		}
+		out, err := exec.Command("minisign", "-V", "-q", "-P", minisignKey, "-m", unverified, "-x", signature).CombinedOutput()
+		if err != nil {
+			os.Remove(unverified)
+			os.Remove(signature)
+			log.Fatalf("%s Config from %s failed signature verification: %v: %s", sym.fail, source, err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	if err := os.Rename(unverified, path); err != nil {
+		log.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	fmt.Printf("Fetched config from %s (sha256 %s)\n", label, digest)
+	return path
+}
+
+// fetchRemote downloads url through the repository, so saved credentials apply
+func fetchRemote(baseDir string, url string) []byte {
+	dest, err := os.CreateTemp(filepath.Join(baseDir, "configs"), "fetch-")
+	if err != nil {
+		log.Fatalf("Failed to create temp file: %v", err)
+	}
+	dest.Close()
+	os.Remove(dest.Name())
+	defer os.Remove(dest.Name())
+
+	if err := newRepository(baseDir).DownloadFile(context.Background(), url, dest.Name()); err != nil {
+		log.Fatalf("Failed to fetch %s: %v", url, err)
+	}
+	data, err := os.ReadFile(dest.Name())
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", dest.Name(), err)
+	}
+	return data
+}
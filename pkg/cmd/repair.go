@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+	"github.com/crbroughton/pkg-exploration/pkg/sealed"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+)
+
+// Repair reinstalls applied packages whose store entries were deleted or
+// damaged, all of them or just those named. Shims and exec call it when
+// they find a package missing.
+func Repair(args []string) {
+	baseDir := yourpmDir()
+	applied := loadState(baseDir)
+	if applied.Environment == "" {
+		log.Fatalf("Nothing has been applied yet, run yourpm switch first")
+	}
+
+	con := newConsole()
+	eng := repairEngine(baseDir, applied)
+	eng.Observer = con
+
+	names := args
+	if len(names) == 0 {
+		names = eng.Damaged()
+	}
+	if len(names) == 0 {
+		fmt.Printf("%s Every applied package is intact\n", sym.ok)
+		return
+	}
+
+	err := eng.Repair(context.Background(), repairSettings(applied), names)
+	for _, name := range names {
+		con.flush(name)
+	}
+	var pkgErr *engine.PackageError
+	if errors.As(err, &pkgErr) {
+		log.Fatalf("  %s %v", sym.fail, pkgErr.Err)
+	}
+	if err != nil {
+		log.Fatalf("%s %v", sym.fail, err)
+	}
+	fmt.Printf("%s Repaired %d packages\n", sym.ok, len(names))
+}
+
+// repairMissing is the fast path exec takes before running a command:
+// a stat per applied package, and a quiet reinstall of any that are gone.
+// The manifest and config are only read once something is missing, and
+// nothing here exits, so the command runs whatever happens.
+func repairMissing(baseDir string, applied *state.State) {
+	st := store.NewStore(filepath.Join(baseDir, "store"))
+	damaged := (&engine.Engine{Store: st, Previous: applied}).Damaged()
+	if len(damaged) == 0 {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%s Reinstalling %d missing packages\n", sym.warn, len(damaged))
+	settings := repairSettings(applied)
+	caBundle = settings.CABundle
+	repo, err := openRepository(baseDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", sym.fail, err)
+		return
+	}
+	// The state records every URL; the manifest only adds library files
+	mfst, err := manifest.LoadManifestWithOverlays(filepath.Join(baseDir, "manifest.toml"), filepath.Join(baseDir, "manifest.d"))
+	if err == nil {
+		err = sealed.Open(mfst, ageIdentity(baseDir))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s Failed to load manifest, reinstalling from the state alone: %v\n", sym.warn, err)
+		mfst = &manifest.Manifest{}
+	}
+
+	eng := &engine.Engine{
+		BaseDir:  baseDir,
+		Manifest: mfst,
+		Repo:     repo,
+		Store:    st,
+		Profile:  profile.NewProfile(filepath.Join(baseDir, "profiles", "default")),
+		Observer: engine.NopObserver{},
+		Previous: applied,
+	}
+	if err := eng.Repair(context.Background(), settings, damaged); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", sym.fail, err)
+	}
+}
+
+// repairEngine is an engine that reinstalls from the state rather than a
+// config, so it works even if the config has changed since
+func repairEngine(baseDir string, applied *state.State) *engine.Engine {
+	prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
+	mfst := loadManifest(baseDir, &config.Config{Settings: config.Settings{Strictness: config.StrictnessLenient}})
+	caBundle = repairSettings(applied).CABundle
+	eng := newEngine(baseDir, mfst, prof)
+	eng.Previous = applied
+	eng.Observer = engine.NopObserver{}
+	return eng
+}
+
+// repairSettings are the settings of the applied config, or the defaults
+// if it can't be read any more
+func repairSettings(applied *state.State) config.Settings {
+	if cfg, err := config.LoadConfig(applied.Config); err == nil {
+		return cfg.Settings
+	}
+	return config.Settings{Parallelism: 4, Gatekeeper: config.GatekeeperUnquarantine}
+}
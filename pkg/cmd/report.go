@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
+	"github.com/crbroughton/pkg-exploration/pkg/events"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+)
+
+func eventsPath(baseDir string) string {
+	return filepath.Join(baseDir, "events.jsonl")
+}
+
+// recordSwitch logs the packages a switch installed, updated or removed,
+// and what it downloaded for them
+func recordSwitch(baseDir string, previous *state.State, applied *state.State, downloaded map[string]int64) {
+	var changes []events.Event
+	for _, name := range slices.Sorted(maps.Keys(applied.Packages)) {
+		pkg := applied.Packages[name]
+		event := events.Event{Time: applied.AppliedAt, Package: name, Version: pkg.Version, Bytes: downloaded[name]}
+		before, ok := previous.Packages[name]
+		switch {
+		case !ok:
+			event.Action = events.Installed
+		case before.Version != pkg.Version:
+			event.Action = events.Updated
+			event.From = before.Version
+		default:
+			continue
+		}
+		changes = append(changes, event)
+	}
+	for _, name := range slices.Sorted(maps.Keys(previous.Packages)) {
+		if _, ok := applied.Packages[name]; !ok {
+			changes = append(changes, events.Event{Time: applied.AppliedAt, Action: events.Removed, Package: name, Version: previous.Packages[name].Version})
+		}
+	}
+
+	if err := events.Append(eventsPath(baseDir), changes); err != nil {
+		warn([]string{fmt.Sprintf("Failed to log changes: %v", err)})
+	}
+}
+
+// Report summarises what switch and gc changed on this machine recently
+func Report(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	sinceFlag := flags.String("since", "7d", "how far back to report, in days (7d) or as a Go duration (12h)")
+	flags.Parse(args)
+
+	age, err := parseAge(*sinceFlag)
+	if err != nil {
+		log.Fatalf("Bad --since %q: %v", *sinceFlag, err)
+	}
+	since := time.Now().Add(-age)
+
+	logged, err := events.Read(eventsPath(yourpmDir()), since)
+	if err != nil {
+		log.Fatalf("Failed to read the change log: %v", err)
+	}
+	if len(logged) == 0 {
+		fmt.Printf("%s Nothing changed since %s\n", sym.ok, since.Local().Format("2006-01-02 15:04"))
+		return
+	}
+
+	fmt.Printf("Changes since %s\n", since.Local().Format("2006-01-02 15:04"))
+	var downloaded, freed int64
+	for _, action := range []string{events.Installed, events.Updated, events.Removed, events.Pruned} {
+		var lines []string
+		for _, event := range logged {
+			if event.Action != action {
+				continue
+			}
+			version := event.Version
+			if event.From != "" {
+				version = fmt.Sprintf("%s %s %s", event.From, sym.arrow, event.Version)
+			}
+			line := fmt.Sprintf("  %s  %s %s", event.Time.Local().Format("2006-01-02 15:04"), event.Package, version)
+			if action == events.Pruned {
+				freed += event.Bytes
+			} else if event.Bytes > 0 {
+				downloaded += event.Bytes
+				line += fmt.Sprintf(" (downloaded %s)", engine.FormatBytes(uint64(event.Bytes)))
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) > 0 {
+			fmt.Printf("\n%s%s (%d):\n%s\n", strings.ToUpper(action[:1]), action[1:], len(lines), strings.Join(lines, "\n"))
+		}
+	}
+
+	fmt.Printf("\nDownloaded %s, freed %s by pruning\n", engine.FormatBytes(uint64(downloaded)), engine.FormatBytes(uint64(freed)))
+}
+
+// parseAge reads a duration that may also be given in days, like 7d
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("want a whole number of days")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
+	"github.com/crbroughton/pkg-exploration/pkg/generation"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+	"github.com/crbroughton/pkg-exploration/pkg/sealed"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+)
+
+// Restore rebuilds the profile, links and activation script, from the state
+// and whatever the store and download cache still hold, for when the
+// profile was deleted or damaged. Nothing is downloaded. If the state itself
+// won't load, the latest generation stands in for it.
+func Restore(args []string) {
+	if len(args) > 0 {
+		log.Fatalf("Usage: yourpm restore")
+	}
+
+	baseDir := yourpmDir()
+	// No repository: nothing here may touch the network
+	eng := &engine.Engine{
+		BaseDir:  baseDir,
+		Manifest: loadManifest(baseDir, &config.Config{Settings: config.Settings{Strictness: config.StrictnessLenient}}),
+		Store:    store.NewStore(filepath.Join(baseDir, "store")),
+		Profile:  profile.NewProfile(filepath.Join(baseDir, "profiles", "default")),
+	}
+
+	applied, err := state.Load(statePath(baseDir))
+	fromGeneration := err != nil
+	if fromGeneration {
+		warn([]string{fmt.Sprintf("%v, restoring from the latest generation instead", err)})
+		applied, err = stateFromGeneration(baseDir, eng)
+		if err != nil {
+			log.Fatalf("Failed to restore: %v", err)
+		}
+	}
+	if applied.Environment == "" && len(applied.Packages) == 0 {
+		log.Fatalf("Nothing has been applied yet, run yourpm switch first")
+	}
+	eng.Previous = applied
+
+	cfg := restoreConfig(baseDir, applied)
+	con := newConsole()
+	eng.Observer = con
+	lost, err := eng.Restore(cfg)
+	for _, name := range slices.Sorted(maps.Keys(applied.Packages)) {
+		con.flush(name)
+	}
+	var pkgErr *engine.PackageError
+	if errors.As(err, &pkgErr) {
+		log.Fatalf("  %s %v", sym.fail, pkgErr.Err)
+	}
+	if err != nil {
+		log.Fatalf("%s %v", sym.fail, err)
+	}
+	if len(eng.Broken) > 0 {
+		fmt.Fprintf(os.Stderr, "%s %d commands were linked but won't run:\n", sym.warn, len(eng.Broken))
+		for _, link := range eng.Broken {
+			fmt.Fprintf(os.Stderr, "  %s (%s): %s\n", link.Binary, link.Package, link.Cause)
+		}
+	}
+
+	for _, name := range lost {
+		delete(applied.Packages, name)
+	}
+	_, envWarnings := applied.Env()
+	warn(envWarnings)
+	if err := eng.Profile.WriteActivation(activation(cfg, applied)); err != nil {
+		log.Fatalf("Failed to write activation script: %v", err)
+	}
+	if err := eng.Profile.WriteFingerprint(applied.Config, applied.ConfigHash); err != nil {
+		log.Fatalf("Failed to write fingerprint: %v", err)
+	}
+	if fromGeneration {
+		if err := applied.Save(statePath(baseDir)); err != nil {
+			log.Fatalf("Failed to save state: %v", err)
+		}
+	}
+
+	fmt.Printf("%s Restored %d packages\n", sym.ok, len(applied.Packages))
+	if len(lost) > 0 {
+		fmt.Fprintf(os.Stderr, "%s Not in the store or cache, run yourpm switch to download again: %s\n", sym.fail, strings.Join(lost, ", "))
+		os.Exit(1)
+	}
+}
+
+// restoreConfig is the applied config, with the same hosts sections as when
+// it was applied, for its link mode, wrappers and env. If it can't be read
+// any more, packages are linked with the default settings.
+func restoreConfig(baseDir string, applied *state.State) *config.Config {
+	cfg, err := config.LoadConfig(applied.Config)
+	if err == nil {
+		err = sealed.Open(cfg, ageIdentity(baseDir))
+	}
+	if err != nil {
+		warn([]string{fmt.Sprintf("Failed to load config from %s, linking with default settings: %v", applied.Config, err)})
+		return &config.Config{Settings: config.Settings{Gatekeeper: config.GatekeeperUnquarantine}}
+	}
+	applyHosts(cfg, applied.Tags)
+	return cfg
+}
+
+// stateFromGeneration rebuilds a state from the latest generation's package
+// versions and the manifest. Generations don't record checksums, so cached
+// downloads are extracted without checking them.
+func stateFromGeneration(baseDir string, eng *engine.Engine) (*state.State, error) {
+	mfst := eng.Manifest
+	generations, err := generation.NewHistory(generationsDir(baseDir)).List()
+	if err != nil {
+		return nil, err
+	}
+	if len(generations) == 0 {
+		return nil, fmt.Errorf("no generations recorded")
+	}
+	latest := generations[len(generations)-1]
+
+	applied := &state.State{
+		Environment: latest.Environment,
+		Config:      latest.Config,
+		AppliedAt:   latest.CreatedAt,
+		Packages:    make(map[string]state.PackageState),
+	}
+	var names []string
+	for name := range latest.Packages {
+		if _, err := mfst.GetPackage(name); err != nil {
+			warn([]string{fmt.Sprintf("Skipping %s: no longer in the manifest", name)})
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var prefer map[string]string
+	if cfg, err := config.LoadConfig(latest.Config); err == nil {
+		prefer = cfg.Settings.Providers
+	}
+	owners, _, err := mfst.BinaryOwners(names, prefer)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		version := latest.Packages[name]
+		url, err := engine.ResolveURL(context.Background(), eng.Repo, mfst, name, version, manifest.Platform())
+		if err != nil {
+			return nil, fmt.Errorf("%s@%s: %w", name, version, err)
+		}
+		pkgDef, _ := mfst.GetPackage(name)
+		pkg := state.PackageState{
+			Version:   version,
+			URL:       url,
+			Manifest:  mfst.Source(name),
+			StorePath: eng.Store.Path(name, version),
+		}
+		for _, binary := range pkgDef.Binaries.Names {
+			if owners[binary] == name {
+				pkg.Binaries = append(pkg.Binaries, binary)
+			}
+		}
+		dataDir := filepath.Join(baseDir, "data", name)
+		if pkgDef.UsesDataDir() {
+			pkg.DataDir = dataDir
+		}
+		pkg.Env = pkgDef.ExpandEnv(pkg.StorePath, dataDir)
+		pkg.LibDir, pkg.CertFile = pkgDef.LibraryPaths(pkg.StorePath)
+		applied.Packages[name] = pkg
+	}
+	return applied, nil
+}
@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const scheduleName = "yourpm-refresh"
+
+// scheduleIntervals maps an interval to a systemd OnCalendar value and launchd seconds
+var scheduleIntervals = map[string]struct {
+	calendar string
+	seconds  int
+}{
+	"hourly": {"hourly", 60 * 60},
+	"daily":  {"daily", 24 * 60 * 60},
+	"weekly": {"weekly", 7 * 24 * 60 * 60},
+}
+
+const systemdService = `# Generated by yourpm, do not edit
+[Unit]
+Description=Refresh the yourpm environment
+
+[Service]
+Type=oneshot
+%s`
+
+const systemdTimer = `# Generated by yourpm, do not edit
+[Unit]
+Description=Refresh the yourpm environment %s
+
+[Timer]
+OnCalendar=%s
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+
+const launchdAgent = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<!-- Generated by yourpm, do not edit -->
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>dev.yourpm.refresh</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>StartInterval</key>
+	<integer>%d</integer>
+	<key>StandardOutPath</key>
+	<string>%s</string>
+	<key>StandardErrorPath</key>
+	<string>%s</string>
+</dict>
+</plist>
+`
+
+type scheduleFile struct {
+	path    string
+	content string
+}
+
+// Schedule installs or removes a systemd user timer (Linux) or launchd
+// agent (macOS) that re-applies the config in the background
+func Schedule(args []string) {
+	if len(args) == 0 {
+		log.Fatalf("Usage: yourpm schedule install [--interval daily] [--config file] [--notify] [--webhook url] [--print] | remove")
+	}
+
+	switch args[0] {
+	case "install":
+		scheduleInstall(args[1:])
+	case "remove":
+		scheduleRemove()
+	default:
+		log.Fatalf("Unknown schedule command: %s", args[0])
+	}
+}
+
+func scheduleInstall(args []string) {
+	flags := flag.NewFlagSet("schedule install", flag.ExitOnError)
+	interval := flags.String("interval", "daily", "how often to refresh: hourly, daily or weekly")
+	configFile := flags.String("config", "", "config to apply, defaults to the one last switched to")
+	notify := flags.Bool("notify", false, "also run yourpm outdated --notify after each refresh")
+	webhook := flags.String("webhook", "", "also post outdated packages to this URL after each refresh")
+	printOnly := flags.Bool("print", false, "print the generated files instead of installing them")
+	flags.Parse(args)
+
+	every, ok := scheduleIntervals[*interval]
+	if !ok {
+		log.Fatalf("Unknown interval %q, use hourly, daily or weekly", *interval)
+	}
+
+	baseDir := yourpmDir()
+	if *configFile == "" {
+		*configFile = loadState(baseDir).Config
+	}
+	configPath, _ := loadConfig(baseDir, configArgs(*configFile))
+
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Failed to find the yourpm executable: %v", err)
+	}
+	commands := [][]string{{exe, "switch", configPath}}
+	if *notify || *webhook != "" {
+		outdated := []string{exe, "outdated", "--config", configPath}
+		if *notify {
+			outdated = append(outdated, "--notify")
+		}
+		if *webhook != "" {
+			outdated = append(outdated, "--webhook", *webhook)
+		}
+		commands = append(commands, outdated)
+	}
+
+	var files []scheduleFile
+	var enable [][]string
+	switch runtime.GOOS {
+	case "linux":
+		dir := systemdUserDir()
+		var execStart strings.Builder
+		for _, command := range commands {
+			quoted := make([]string, len(command))
+			for i, arg := range command {
+				quoted[i] = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+			}
+			fmt.Fprintf(&execStart, "ExecStart=%s\n", strings.Join(quoted, " "))
+		}
+		files = []scheduleFile{
+			{filepath.Join(dir, scheduleName+".service"), fmt.Sprintf(systemdService, execStart.String())},
+			{filepath.Join(dir, scheduleName+".timer"), fmt.Sprintf(systemdTimer, *interval, every.calendar)},
+		}
+		enable = [][]string{
+			{"systemctl", "--user", "daemon-reload"},
+			{"systemctl", "--user", "enable", "--now", scheduleName + ".timer"},
+		}
+	case "darwin":
+		// launchd runs one program, so several commands go through sh
+		program := commands[0]
+		if len(commands) > 1 {
+			var script []string
+			for _, command := range commands {
+				quoted := make([]string, len(command))
+				for i, arg := range command {
+					quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+				}
+				script = append(script, strings.Join(quoted, " "))
+			}
+			program = []string{"/bin/sh", "-c", strings.Join(script, "; ")}
+		}
+
+		var arguments strings.Builder
+		for _, arg := range program {
+			fmt.Fprintf(&arguments, "\t\t<string>%s</string>\n", xmlEscape(arg))
+		}
+		logPath := filepath.Join(baseDir, "schedule.log")
+		agent := launchdAgentPath()
+		files = []scheduleFile{
+			{agent, fmt.Sprintf(launchdAgent, arguments.String(), every.seconds, xmlEscape(logPath), xmlEscape(logPath))},
+		}
+		enable = [][]string{{"launchctl", "load", "-w", agent}}
+	default:
+		log.Fatalf("Scheduling is not supported on %s", runtime.GOOS)
+	}
+
+	if *printOnly {
+		for _, file := range files {
+			fmt.Printf("# %s\n%s\n", file.path, file.content)
+		}
+		return
+	}
+
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+			log.Fatalf("Failed to create %s: %v", filepath.Dir(file.path), err)
+		}
+		if err := os.WriteFile(file.path, []byte(file.content), 0644); err != nil {
+			log.Fatalf("Failed to write %s: %v", file.path, err)
+		}
+		fmt.Printf("%s Wrote %s\n", sym.ok, file.path)
+	}
+	for _, argv := range enable {
+		if err := run(argv); err != nil {
+			log.Fatalf("Failed to run %s: %v", strings.Join(argv, " "), err)
+		}
+	}
+	fmt.Printf("%s %s will be applied %s\n", sym.ok, configPath, *interval)
+}
+
+func scheduleRemove() {
+	var files []string
+	var disable [][]string
+	switch runtime.GOOS {
+	case "linux":
+		dir := systemdUserDir()
+		files = []string{filepath.Join(dir, scheduleName+".service"), filepath.Join(dir, scheduleName+".timer")}
+		disable = [][]string{{"systemctl", "--user", "disable", "--now", scheduleName + ".timer"}}
+	case "darwin":
+		files = []string{launchdAgentPath()}
+		disable = [][]string{{"launchctl", "unload", "-w", launchdAgentPath()}}
+	default:
+		log.Fatalf("Scheduling is not supported on %s", runtime.GOOS)
+	}
+
+	if _, err := os.Stat(files[0]); os.IsNotExist(err) {
+		fmt.Println("No schedule installed")
+		return
+	}
+	for _, argv := range disable {
+		if err := run(argv); err != nil {
+			warn([]string{fmt.Sprintf("%s failed: %v", strings.Join(argv, " "), err)})
+		}
+	}
+	for _, path := range files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Failed to remove %s: %v", path, err)
+		}
+	}
+	fmt.Printf("%s Removed the schedule\n", sym.ok)
+}
+
+func systemdUserDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		log.Fatalf("Failed to find the user config dir: %v", err)
+	}
+	return filepath.Join(configDir, "systemd", "user")
+}
+
+func launchdAgentPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "Library", "LaunchAgents", "dev.yourpm.refresh.plist")
+}
+
+func xmlEscape(value string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(value)
+}
+
+func run(argv []string) error {
+	c := exec.Command(argv[0], argv[1:]...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/schema"
+)
+
+// Schema prints the JSON Schema of the config or manifest format, for
+// editors that complete and check TOML against one
+func Schema(args []string) {
+	if len(args) != 2 || args[0] != "dump" {
+		log.Fatalf("Usage: yourpm schema dump config|manifest")
+	}
+
+	var s map[string]any
+	switch args[1] {
+	case "config":
+		s = schema.Generate("yourpm config", config.Config{})
+	case "manifest":
+		s = schema.Generate("yourpm manifest", manifest.Manifest{})
+	default:
+		log.Fatalf("Unknown schema %q, expected config or manifest", args[1])
+	}
+
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode schema: %v", err)
+	}
+	fmt.Println(string(out))
+}
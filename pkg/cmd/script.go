@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// RunScript runs one of the config's [scripts] with sh in the profile
+// environment. The script is run unchanged; extra arguments become its
+// positional parameters, so scripts refer to "$@" themselves to use them.
+func RunScript(args []string) {
+	flags := flag.NewFlagSet("run-script", flag.ExitOnError)
+	noColorFlag(flags)
+	configFile := flags.String("config", "", "config file to take the scripts and environment from")
+	flags.Parse(args)
+
+	baseDir := yourpmDir()
+	configPath, cfg := loadConfig(baseDir, configArgs(*configFile))
+
+	if flags.NArg() == 0 {
+		listScripts(configPath, cfg.Scripts)
+		return
+	}
+
+	name := flags.Arg(0)
+	script, ok := cfg.Scripts[name]
+	if !ok {
+		log.Fatalf("No script %q in %s", name, configPath)
+	}
+
+	// sh -c takes $0 from the next argument, then "$@" from the rest
+	command := append([]string{"sh", "-c", script, name}, flags.Args()[1:]...)
+	runInEnvironment(baseDir, configPath, cfg, command)
+}
+
+func listScripts(configPath string, scripts map[string]string) {
+	if len(scripts) == 0 {
+		fmt.Printf("No scripts in %s\n", configPath)
+		return
+	}
+
+	names := make([]string, 0, len(scripts))
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%-12s %s\n", name, scripts[name])
+	}
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/crbroughton/pkg-exploration/pkg/sealed"
+)
+
+// Seal encrypts a value read from stdin for pasting into a config or
+// manifest, where it is decrypted again at load time
+func Seal(args []string) {
+	flags := flag.NewFlagSet("seal", flag.ExitOnError)
+	var recipients stringsFlag
+	flags.Var(&recipients, "r", "age recipient to encrypt to (repeatable), defaults to your own key")
+	flags.Parse(args)
+
+	value, err := readSecret("Value: ")
+	if err != nil {
+		log.Fatalf("Failed to read value: %v", err)
+	}
+	if value == "" {
+		log.Fatalf("No value given")
+	}
+
+	out, err := sealed.Seal(value, ageIdentity(yourpmDir()), recipients)
+	if err != nil {
+		log.Fatalf("%s %v", sym.fail, err)
+	}
+	fmt.Println(out)
+}
+
+// ageIdentity is the age key sealed values are decrypted with,
+// ~/.yourpm/age.key unless YOURPM_AGE_IDENTITY names another
+func ageIdentity(baseDir string) string {
+	if path := os.Getenv("YOURPM_AGE_IDENTITY"); path != "" {
+		return path
+	}
+	return filepath.Join(baseDir, "age.key")
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/crbroughton/pkg-exploration/pkg/generation"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+	"github.com/crbroughton/pkg-exploration/pkg/snapshot"
+)
+
+// Snapshot saves the applied environment under a name, or restores one
+func Snapshot(args []string) {
+	baseDir := yourpmDir()
+	snapshots := snapshot.New(snapshotsDir(baseDir))
+
+	if len(args) == 0 || args[0] == "list" {
+		listSnapshots(snapshots)
+		return
+	}
+	if len(args) != 2 {
+		log.Fatalf("Usage: yourpm snapshot create|restore|delete <name>")
+	}
+
+	switch args[0] {
+	case "create":
+		createSnapshot(baseDir, snapshots, args[1])
+	case "restore":
+		restoreSnapshot(baseDir, snapshots, args[1])
+	case "delete":
+		if err := snapshots.Delete(args[1]); err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Printf("%s Deleted snapshot %s\n", sym.removed, args[1])
+	default:
+		log.Fatalf("Unknown snapshot command: %s", args[0])
+	}
+}
+
+func listSnapshots(snapshots *snapshot.Snapshots) {
+	list, err := snapshots.List()
+	if err != nil {
+		log.Fatalf("Failed to read snapshots: %v", err)
+	}
+	if len(list) == 0 {
+		fmt.Println("No snapshots yet, create one with yourpm snapshot create <name>")
+		return
+	}
+
+	for _, snap := range list {
+		fmt.Printf("%-16s %s  %-12s %d packages", snap.Name, snap.CreatedAt.Local().Format("2006-01-02 15:04"), snap.Environment, len(snap.Packages))
+		if snap.Generation > 0 {
+			fmt.Printf("  generation %d", snap.Generation)
+		}
+		fmt.Println()
+	}
+}
+
+func createSnapshot(baseDir string, snapshots *snapshot.Snapshots, name string) {
+	applied := loadState(baseDir)
+	if applied.Environment == "" {
+		log.Fatalf("Nothing has been applied yet, run yourpm switch first")
+	}
+
+	snap := snapshot.Snapshot{
+		Name:        name,
+		CreatedAt:   time.Now().UTC(),
+		Environment: applied.Environment,
+		Config:      applied.Config,
+		Packages:    make(map[string]string, len(applied.Packages)),
+	}
+	for pkg, ps := range applied.Packages {
+		snap.Packages[pkg] = ps.Version
+	}
+	if generations, err := generation.NewHistory(generationsDir(baseDir)).List(); err == nil && len(generations) > 0 {
+		snap.Generation = generations[len(generations)-1].Number
+	}
+
+	err := snapshots.Create(snap, snapshot.Sources{
+		State:       statePath(baseDir),
+		Manifest:    filepath.Join(baseDir, "manifest.toml"),
+		ManifestDir: filepath.Join(baseDir, "manifest.d"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if configChanged(applied) {
+		warn([]string{fmt.Sprintf("%s has changed since it was applied, the snapshot keeps the file as it is now", applied.Config)})
+	}
+	fmt.Printf("%s Saved %s with %d packages as snapshot %s\n", sym.ok, applied.Environment, len(snap.Packages), name)
+}
+
+// restoreSnapshot puts the snapshot's config back where it came from and
+// applies it with the snapshot's manifests. Its store entries are kept by gc,
+// so nothing needs downloading.
+func restoreSnapshot(baseDir string, snapshots *snapshot.Snapshots, name string) {
+	snap, err := snapshots.Get(name)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	dir := snapshots.Dir(name)
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.toml"))
+	if err != nil {
+		log.Fatalf("Failed to read snapshot config: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(snap.Config), 0755); err != nil {
+		log.Fatalf("Failed to restore %s: %v", snap.Config, err)
+	}
+	if err := os.WriteFile(snap.Config, data, 0644); err != nil {
+		log.Fatalf("Failed to restore %s: %v", snap.Config, err)
+	}
+	fmt.Printf("Restored %s from snapshot %s\n\n", snap.Config, name)
+
+	configPath, cfg := loadConfig(baseDir, []string{snap.Config})
+	mfst := loadManifestFrom(baseDir, filepath.Join(dir, "manifest.toml"), filepath.Join(dir, "manifest.d"), cfg)
+	prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
+
+	applied := applyOrExit(context.Background(), newEngine(baseDir, mfst, prof), configPath, cfg)
+	recordGeneration(baseDir, configPath, applied, "restore snapshot "+name)
+
+	fmt.Printf("%s Environment '%s' is restored from snapshot %s\n", sym.ok, cfg.Name, name)
+}
+
+func snapshotsDir(baseDir string) string {
+	return filepath.Join(baseDir, "snapshots")
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// deprecated warns about deprecated keys, flags or commands in use, or with
+// YOURPM_DEPRECATIONS=error fails on the first, so automation can catch them
+// before the release that removes them
+func deprecated(warnings []string) {
+	if len(warnings) > 0 && os.Getenv("YOURPM_DEPRECATIONS") == "error" {
+		log.Fatalf("%s (YOURPM_DEPRECATIONS=error)", warnings[0])
+	}
+	warn(warnings)
+}
+
+// outputVersion reads an --output value naming a versioned JSON contract,
+// like json=v1, and returns the version asked for. Plain json means the
+// newest. A version this yourpm doesn't write is fatal, so a script pinned
+// to one never reads a contract it doesn't expect.
+func outputVersion(command string, value string, newest int) int {
+	format, version, _ := strings.Cut(value, "=")
+	if format != "json" {
+		log.Fatalf("%s --output: unknown format %q, want json=v1", command, value)
+	}
+	if version == "" {
+		return newest
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(version, "v"))
+	if err != nil || n < 1 || n > newest {
+		log.Fatalf("%s --output: this yourpm writes %s, not %s", command, outputVersions(newest), value)
+	}
+	return n
+}
+
+// outputFlagHelp describes a command's --output flag
+func outputFlagHelp(newest int) string {
+	return "print JSON in a pinned contract version, " + outputVersions(newest)
+}
+
+func outputVersions(newest int) string {
+	if newest == 1 {
+		return "json=v1"
+	}
+	return fmt.Sprintf("json=v1 to json=v%d", newest)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Status shows what is applied and its environment hash. With --expect it
+// fails unless the hash matches, so CI can check it runs the same toolchain
+// it was pinned to.
+func Status(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	hashOnly := flags.Bool("hash", false, "print only the environment hash")
+	expect := flags.String("expect", "", "exit 1 unless the environment hash is this")
+	flags.Parse(args)
+
+	applied := loadState(yourpmDir())
+	if applied.Environment == "" {
+		log.Fatalf("Nothing has been applied yet, run yourpm switch first")
+	}
+	hash := applied.Hash()
+
+	if *hashOnly {
+		fmt.Println(hash)
+	} else {
+		fmt.Printf("Environment: %s\n", applied.Environment)
+		fmt.Printf("Config:      %s\n", applied.Config)
+		fmt.Printf("Applied:     %s\n", applied.AppliedAt.Local().Format("2006-01-02 15:04:05"))
+		fmt.Printf("Packages:    %d\n", len(applied.Packages))
+		fmt.Printf("Hash:        %s\n", hash)
+		if configChanged(applied) {
+			fmt.Printf("\n%s The config asks for other packages than were applied, run yourpm switch\n", sym.warn)
+		}
+	}
+
+	if *expect != "" && *expect != hash {
+		fmt.Fprintf(os.Stderr, "%s Environment hash is %s, expected %s\n", sym.fail, hash, *expect)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+	"github.com/crbroughton/pkg-exploration/pkg/version"
+)
+
+// Try installs a package into a throwaway store and profile, runs a command
+// from it once and deletes them again. The config, state and active profile
+// are left alone; only the download cache is shared, so trying it again or
+// switching to it later doesn't download twice.
+func Try(args []string) {
+	spec, command := args, []string(nil)
+	if i := slices.Index(args, "--"); i >= 0 {
+		spec, command = args[:i], args[i+1:]
+	}
+	if len(spec) != 1 {
+		log.Fatalf("Usage: yourpm try <package>[@version] [-- <command> [args...]]")
+	}
+	name, wanted, _ := strings.Cut(spec[0], "@")
+
+	baseDir := yourpmDir()
+	// The settings of the config last switched to, if there is one
+	cfg := &config.Config{Settings: config.Settings{Parallelism: 1, Gatekeeper: config.GatekeeperUnquarantine}}
+	configPath := configPathFrom(baseDir, configArgs(loadState(baseDir).Config))
+	if _, err := os.Stat(configPath); err == nil {
+		_, cfg = loadConfig(baseDir, []string{configPath})
+	}
+	mfst := loadManifest(baseDir, cfg)
+	pkgDef, err := mfst.GetPackage(name)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	eng := &engine.Engine{
+		BaseDir:  baseDir,
+		Manifest: mfst,
+		Repo:     newRepository(baseDir),
+	}
+	ctx := context.Background()
+	tried := &config.Config{
+		Name:     "try",
+		Packages: map[string]string{name: tryVersion(ctx, eng, store.NewStore(filepath.Join(baseDir, "store")), pkgDef, name, wanted)},
+		Settings: cfg.Settings,
+	}
+
+	// Created only once nothing before the install can fail, so every exit
+	// from here on removes it
+	tmp, err := os.MkdirTemp("", "yourpm-try-")
+	if err != nil {
+		log.Fatalf("Failed to create a throwaway profile: %v", err)
+	}
+	eng.Store = store.NewStore(filepath.Join(tmp, "store"))
+	eng.Profile = profile.NewProfile(filepath.Join(tmp, "profile"))
+
+	fmt.Fprintf(os.Stderr, "%s Trying %s@%s\n", sym.pkg, name, tried.Packages[name])
+	applied, err := eng.Apply(ctx, tried, "")
+	if err != nil {
+		os.RemoveAll(tmp)
+		var pkgErr *engine.PackageError
+		if errors.As(err, &pkgErr) {
+			log.Fatalf("%s %v", sym.fail, pkgErr.Err)
+		}
+		log.Fatalf("%s %v", sym.fail, err)
+	}
+
+	if len(command) == 0 {
+		binaries := applied.Packages[name].Binaries
+		if len(binaries) == 0 {
+			os.RemoveAll(tmp)
+			log.Fatalf("%s doesn't link any commands, give one after --", name)
+		}
+		command = binaries[:1]
+	}
+	code, err := runActivated(eng.Profile, activation(tried, applied), command)
+	os.RemoveAll(tmp)
+	if err != nil {
+		log.Fatalf("Failed to run %s: %v", command[0], err)
+	}
+	os.Exit(code)
+}
+
+// tryVersion picks the version of name to try. With none given it is the
+// latest release. A partial version like 14 is the newest stored version it
+// prefixes, or the latest release if that matches, and otherwise taken as
+// written.
+func tryVersion(ctx context.Context, eng *engine.Engine, st *store.Store, pkgDef *manifest.PackageDefinition, name string, wanted string) string {
+	matches := func(v string) bool {
+		return wanted == "" || v == wanted || strings.HasPrefix(v, wanted+".")
+	}
+
+	if wanted != "" {
+		names := []string{name}
+		for other := range eng.Manifest.Packages {
+			names = append(names, other)
+		}
+		entries, err := st.Entries(names)
+		if err != nil {
+			log.Fatalf("Failed to read store: %v", err)
+		}
+		var stored []string
+		for _, entry := range entries {
+			if entry.Name == name && entry.Complete && matches(entry.Version) {
+				stored = append(stored, entry.Version)
+			}
+		}
+		if newest := version.Newest(stored); newest != "" {
+			return newest
+		}
+	}
+
+	if pkgDef.Repo == "" {
+		if wanted == "" {
+			log.Fatalf("%s has no repo to find its latest release in, give a version with %s@<version>", name, name)
+		}
+		return wanted
+	}
+	tag, err := eng.Repo.LatestReleaseFrom(ctx, pkgDef.Source, pkgDef.Host, pkgDef.Repo)
+	if err != nil {
+		if wanted == "" {
+			log.Fatalf("Failed to find the latest release of %s: %v", name, err)
+		}
+		return wanted
+	}
+	if latest := pkgDef.TagVersion(tag); matches(latest) {
+		return latest
+	}
+	return wanted
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+	"github.com/crbroughton/pkg-exploration/pkg/undo"
+)
+
+func undoPath(baseDir string) string {
+	return filepath.Join(baseDir, "undo.toml")
+}
+
+// recordForUndo saves the store entries and profile links before an
+// operation deletes some of them. Each entry's URL is the one the state
+// recorded if it is applied, otherwise the manifest's for this platform.
+func recordForUndo(baseDir string, operation string, entries []store.Entry, applied *state.State, mfst *manifest.Manifest) error {
+	record := &undo.Record{Operation: operation, CreatedAt: time.Now()}
+	for _, entry := range entries {
+		saved := undo.Entry{Name: entry.Name, Version: entry.Version}
+		if pkg, ok := applied.Packages[entry.Name]; ok && pkg.Version == entry.Version {
+			saved.URL = pkg.URL
+			saved.Binaries = pkg.Binaries
+		} else if mfst != nil {
+			saved.URL, _ = mfst.GetURL(entry.Name, entry.Version)
+			if pkgDef, err := mfst.GetPackage(entry.Name); err == nil {
+				saved.Binaries = pkgDef.Binaries.Names
+			}
+		}
+		record.Entries = append(record.Entries, saved)
+	}
+
+	links, err := undo.Links(filepath.Join(baseDir, "profiles", "default", "bin"))
+	if err != nil {
+		return err
+	}
+	record.Links = links
+	return undo.Save(undoPath(baseDir), record)
+}
+
+// Undo puts back the store entries and profile links the last gc deleted,
+// downloading again whatever is no longer cached
+func Undo(args []string) {
+	if len(args) > 0 {
+		log.Fatalf("Usage: yourpm undo")
+	}
+
+	baseDir := yourpmDir()
+	record, err := undo.Load(undoPath(baseDir))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if record == nil {
+		fmt.Printf("%s Nothing to undo\n", sym.ok)
+		return
+	}
+
+	applied := loadState(baseDir)
+	eng := repairEngine(baseDir, applied)
+
+	var missing []engine.StoreEntry
+	for _, entry := range record.Entries {
+		if eng.Store.Installed(entry.Name, entry.Version) {
+			continue
+		}
+		if entry.URL == "" {
+			warn([]string{fmt.Sprintf("Can't restore %s@%s: no URL was recorded for it", entry.Name, entry.Version)})
+			continue
+		}
+		missing = append(missing, engine.StoreEntry{Name: entry.Name, Version: entry.Version, URL: entry.URL, Binaries: entry.Binaries})
+	}
+
+	if len(missing) > 0 {
+		con := newConsole()
+		eng.Observer = con
+		err := eng.Reinstall(context.Background(), repairSettings(applied), missing)
+		for _, entry := range missing {
+			con.flush(entry.Name)
+		}
+		var pkgErr *engine.PackageError
+		if errors.As(err, &pkgErr) {
+			log.Fatalf("  %s %v", sym.fail, pkgErr.Err)
+		}
+		if err != nil {
+			log.Fatalf("%s %v", sym.fail, err)
+		}
+	}
+
+	// Only links into what is still applied, so packages a later switch
+	// dropped stay gone
+	links := make(map[string]string)
+	for name, target := range record.Links {
+		for _, pkg := range applied.Packages {
+			if strings.HasPrefix(target, pkg.StorePath+string(filepath.Separator)) {
+				links[name] = target
+			}
+		}
+	}
+	prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
+	relinked, err := undo.RestoreLinks(prof.BinDir(), links)
+	if err != nil {
+		log.Fatalf("Failed to restore links: %v", err)
+	}
+
+	if err := os.Remove(undoPath(baseDir)); err != nil {
+		log.Fatalf("Failed to clear undo record: %v", err)
+	}
+	fmt.Printf("%s Undid %s from %s: restored %d store entries and %d links\n",
+		sym.ok, record.Operation, record.CreatedAt.Local().Format("2006-01-02 15:04"), len(missing), len(relinked))
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+	"github.com/crbroughton/pkg-exploration/pkg/version"
+)
+
+// Upgrade moves one package to a newer version
+func Upgrade(args []string) {
+	changeVersion("upgrade", args, 1)
+}
+
+// Downgrade moves one package back to an older version
+func Downgrade(args []string) {
+	changeVersion("downgrade", args, -1)
+}
+
+// changeVersion sets one package's version in the config and applies it.
+// Without an explicit version it picks the nearest version in direction
+// (1 newer, -1 older) that is still in the store, so nothing is downloaded.
+// The config file is only rewritten once the new version is applied.
+func changeVersion(command string, args []string, direction int) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	configFile := flags.String("config", "", "config file to change, defaults to the one last switched to")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		log.Fatalf("Usage: yourpm %s [--config file] <package> [version]", command)
+	}
+	name := flags.Arg(0)
+
+	baseDir := yourpmDir()
+	if *configFile == "" {
+		*configFile = loadState(baseDir).Config
+	}
+	configPath, cfg := loadConfig(baseDir, configArgs(*configFile))
+
+	current, ok := cfg.Packages[name]
+	if !ok {
+		log.Fatalf("%s is not in %s", name, configPath)
+	}
+
+	mfst := loadManifest(baseDir, cfg)
+
+	target := flags.Arg(1)
+	if target == "" {
+		st := store.NewStore(filepath.Join(baseDir, "store"))
+		target = nearestStored(st, mfst, name, current, direction)
+		if target == "" {
+			log.Fatalf("No %s version of %s in the store, give one: yourpm %s %s <version>", newerOrOlder(direction), name, command, name)
+		}
+	}
+	if target == current {
+		fmt.Printf("%s %s is already at %s\n", sym.ok, name, current)
+		return
+	}
+	if version.Compare(target, current) != direction {
+		log.Fatalf("%s %s is not %s than %s", name, target, newerOrOlder(direction), current)
+	}
+
+	prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
+	cfg.Packages[name] = target
+
+	applied := applyOrExit(context.Background(), newEngine(baseDir, mfst, prof), configPath, cfg)
+
+	if err := config.SetPackageVersion(configPath, name, target); err != nil {
+		log.Fatalf("Applied %s@%s but failed to update %s: %v", name, target, configPath, err)
+	}
+	recordGeneration(baseDir, configPath, applied, fmt.Sprintf("%s %s %s → %s", command, name, current, target))
+	fmt.Printf("%s %s %s %s %s\n", sym.ok, name, current, sym.arrow, target)
+}
+
+// nearestStored finds the installed version of name closest to current in direction
+func nearestStored(st *store.Store, mfst *manifest.Manifest, name string, current string, direction int) string {
+	// Every manifest name, so another package's entries aren't mistaken for name's
+	names := []string{name}
+	for other := range mfst.Packages {
+		names = append(names, other)
+	}
+	entries, err := st.Entries(names)
+	if err != nil {
+		log.Fatalf("Failed to read store: %v", err)
+	}
+
+	nearest := ""
+	for _, entry := range entries {
+		if entry.Name != name || !entry.Complete || version.Compare(entry.Version, current) != direction {
+			continue
+		}
+		if nearest == "" || version.Compare(entry.Version, nearest) == -direction {
+			nearest = entry.Version
+		}
+	}
+	return nearest
+}
+
+func newerOrOlder(direction int) string {
+	if direction > 0 {
+		return "newer"
+	}
+	return "older"
+}
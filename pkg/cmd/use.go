@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/engine"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+)
+
+// Use flips the profile to another named environment applied before,
+// relinking what the store and download cache already hold, or lists the
+// environments there are. "-" is the environment used before this one.
+func Use(args []string) {
+	if len(args) > 1 {
+		log.Fatalf("Usage: yourpm use [<environment>|-]")
+	}
+
+	baseDir := yourpmDir()
+	current := loadState(baseDir)
+	if len(args) == 0 {
+		listEnvironments(baseDir, current)
+		return
+	}
+
+	name := args[0]
+	if name == "-" {
+		data, err := os.ReadFile(filepath.Join(envsDir(baseDir), "previous"))
+		if err != nil {
+			log.Fatalf("No environment was used before %s", current.Environment)
+		}
+		name = strings.TrimSpace(string(data))
+	}
+	if name == "" || name != filepath.Base(name) || name[0] == '.' {
+		log.Fatalf("Invalid environment name %q", name)
+	}
+	if name == current.Environment {
+		fmt.Printf("%s Already using %s\n", sym.ok, name)
+		return
+	}
+	target, err := state.Load(envStatePath(baseDir, name))
+	if err != nil || target.Environment == "" {
+		log.Fatalf("Environment %s hasn't been applied on this machine, run yourpm switch <config> with it first", name)
+	}
+
+	// No repository: flipping never downloads
+	eng := &engine.Engine{
+		BaseDir:  baseDir,
+		Manifest: loadManifest(baseDir, &config.Config{Settings: config.Settings{Strictness: config.StrictnessLenient}}),
+		Store:    store.NewStore(filepath.Join(baseDir, "store")),
+		Profile:  profile.NewProfile(filepath.Join(baseDir, "profiles", "default")),
+		Previous: current,
+	}
+	cfg := restoreConfig(baseDir, target)
+	con := newConsole()
+	eng.Observer = con
+	lost, err := eng.Use(cfg, target)
+	for _, pkg := range slices.Sorted(maps.Keys(target.Packages)) {
+		con.flush(pkg)
+	}
+	if len(lost) > 0 {
+		log.Fatalf("%s Not in the store or cache, run yourpm switch %s to download again: %s", sym.fail, target.Config, strings.Join(lost, ", "))
+	}
+	var pkgErr *engine.PackageError
+	if errors.As(err, &pkgErr) {
+		log.Fatalf("  %s %v", sym.fail, pkgErr.Err)
+	}
+	if err != nil {
+		log.Fatalf("%s %v", sym.fail, err)
+	}
+	if len(eng.Broken) > 0 {
+		fmt.Fprintf(os.Stderr, "%s %d commands were linked but won't run:\n", sym.warn, len(eng.Broken))
+		for _, link := range eng.Broken {
+			fmt.Fprintf(os.Stderr, "  %s (%s): %s\n", link.Binary, link.Package, link.Cause)
+		}
+	}
+
+	_, envWarnings := target.Env()
+	warn(envWarnings)
+	if err := eng.Profile.WriteActivation(activation(cfg, target)); err != nil {
+		log.Fatalf("Failed to write activation script: %v", err)
+	}
+	if err := eng.Profile.WriteFingerprint(target.Config, target.ConfigHash); err != nil {
+		log.Fatalf("Failed to write fingerprint: %v", err)
+	}
+
+	target.AppliedAt = time.Now()
+	if err := target.Save(statePath(baseDir)); err != nil {
+		log.Fatalf("Failed to save state: %v", err)
+	}
+	recordSwitch(baseDir, current, target, nil)
+	recordGeneration(baseDir, target.Config, target, "use "+name)
+	saveEnvironment(baseDir, current, target)
+
+	fmt.Printf("%s Using %s with %d packages\n", sym.ok, name, len(target.Packages))
+}
+
+func listEnvironments(baseDir string, current *state.State) {
+	dirs, err := os.ReadDir(envsDir(baseDir))
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Failed to read environments: %v", err)
+	}
+
+	found := false
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		env, err := state.Load(envStatePath(baseDir, dir.Name()))
+		if err != nil || env.Environment == "" {
+			continue
+		}
+		found = true
+		marker := " "
+		if env.Environment == current.Environment {
+			marker = "*"
+		}
+		fmt.Printf("%s %-16s %s  %d packages  %s\n", marker, env.Environment, env.AppliedAt.Local().Format("2006-01-02 15:04"), len(env.Packages), env.Config)
+	}
+	if !found {
+		fmt.Println("No environments yet, each config's name becomes one when it is switched to")
+	}
+}
+
+// saveEnvironment keeps applied under its environment's name for yourpm
+// use, and remembers the environment it replaced for yourpm use -
+func saveEnvironment(baseDir string, previous *state.State, applied *state.State) {
+	if applied.Environment == "" || applied.Environment != filepath.Base(applied.Environment) || applied.Environment[0] == '.' {
+		return
+	}
+	if err := applied.Save(envStatePath(baseDir, applied.Environment)); err != nil {
+		warn([]string{fmt.Sprintf("Failed to save environment %s: %v", applied.Environment, err)})
+		return
+	}
+	if previous.Environment != "" && previous.Environment != applied.Environment {
+		if err := os.WriteFile(filepath.Join(envsDir(baseDir), "previous"), []byte(previous.Environment+"\n"), 0644); err != nil {
+			warn([]string{fmt.Sprintf("Failed to remember environment %s for yourpm use -: %v", previous.Environment, err)})
+		}
+	}
+}
+
+func envsDir(baseDir string) string {
+	return filepath.Join(baseDir, "envs")
+}
+
+func envStatePath(baseDir string, name string) string {
+	return filepath.Join(envsDir(baseDir), name, "state.toml")
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+)
+
+// vendoredManifest is the file yourpm vendor writes next to the config
+const vendoredManifest = "yourpm-manifest.toml"
+
+// Vendor copies the manifest definitions a config uses, on any host, into a
+// manifest next to it and points the config at it, so the config resolves
+// the same way wherever it is checked out whatever the global manifest
+// says. Running it again refreshes the copy from the global manifest.
+func Vendor(args []string) {
+	baseDir := yourpmDir()
+	configPath, cfg := loadConfig(baseDir, args)
+
+	// Sealed values stay sealed: the copy is meant to be committed
+	global, err := manifest.LoadManifestWithOverlays(filepath.Join(baseDir, "manifest.toml"), filepath.Join(baseDir, "manifest.d"))
+	if err != nil {
+		log.Fatalf("Failed to load manifest: %v", err)
+	}
+
+	wanted := make(map[string]bool)
+	for name := range cfg.Packages {
+		wanted[name] = true
+	}
+	for _, host := range cfg.Hosts {
+		for name := range host.Packages {
+			wanted[name] = true
+		}
+	}
+
+	vendored := &manifest.Manifest{SchemaVersion: manifest.SchemaVersion, Packages: make(map[string]manifest.PackageDefinition)}
+	queue := slices.Sorted(maps.Keys(wanted))
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, ok := vendored.Packages[name]; ok {
+			continue
+		}
+		pkgDef, ok := global.Packages[name]
+		if !ok {
+			log.Fatalf("Package %s is not in the manifest", name)
+		}
+		vendored.Packages[name] = pkgDef
+		// Library packages come along with what requires them
+		queue = append(queue, pkgDef.Requires...)
+	}
+
+	path := cfg.VendoredManifest()
+	if path == "" {
+		path = filepath.Join(filepath.Dir(configPath), vendoredManifest)
+	}
+	if err := writeVendored(path, vendored); err != nil {
+		log.Fatalf("Failed to write %s: %v", path, err)
+	}
+	if cfg.Manifest == "" {
+		if err := config.SetManifest(configPath, vendoredManifest); err != nil {
+			log.Fatalf("Failed to point %s at %s: %v", configPath, path, err)
+		}
+	}
+
+	fmt.Printf("%s Vendored %d package definitions into %s\n", sym.ok, len(vendored.Packages), path)
+}
+
+func writeVendored(path string, m *manifest.Manifest) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(f, "# Written by yourpm vendor from the global manifest, run it again to refresh\n\n")
+	enc := toml.NewEncoder(f)
+	enc.Indent = ""
+	if err := enc.Encode(m); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
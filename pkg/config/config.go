@@ -3,28 +3,123 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/BurntSushi/toml"
+	"github.com/crbroughton/pkg-exploration/pkg/tomlfile"
 )
 
+// SchemaVersion is the newest version of the config format this yourpm
+// reads. It goes up only when an existing key changes meaning or goes away;
+// new keys don't need it.
+const SchemaVersion = 1
+
+// deprecatedKeys still work but are going away. A renamed key is listed here
+// until the release that removes it.
+var deprecatedKeys = []tomlfile.Deprecation{
+	// Setting PATH outright drops the profile's bin dir from it
+	{Key: "env.PATH", Instead: "path_prepend or path_append"},
+	{Key: "hosts.*.env.PATH", Instead: "path_prepend or path_append in the hosts section"},
+}
+
 type Config struct {
-	Name     string            `toml:"name"`
-	Packages map[string]string `toml:"packages"`
+	Name        string            `toml:"name" schema:"required"`
+	Packages    map[string]string `toml:"packages"`
+	Env         map[string]string `toml:"env"`
+	PathPrepend []string          `toml:"path_prepend"`
+	PathAppend  []string          `toml:"path_append"`
+	Settings    Settings          `toml:"settings"`
+
+	// Scripts are named shell commands run in the environment by run-script
+	Scripts map[string]string `toml:"scripts"`
+
+	// Wrappers put command prefixes in front of commands, outermost first,
+	// like terraform = ["aws-vault exec prod --"]
+	Wrappers map[string][]string `toml:"wrappers"`
+
+	// Hosts add packages, env and PATH entries on the machines their key
+	// selects, see ApplyHosts
+	Hosts map[string]HostOverride `toml:"hosts"`
+
+	// Manifest is a manifest vendored with the config, relative to it, used
+	// instead of ~/.yourpm/manifest.toml and manifest.d
+	Manifest string `toml:"manifest,omitempty"`
+
+	// SchemaVersion is the format version the file was written for, 1 if
+	// it doesn't say
+	SchemaVersion int `toml:"schema_version,omitempty"`
+
+	// Warnings about keys in the file that were not recognised
+	Warnings []string `toml:"-"`
+	// Deprecations warn about deprecated keys the file sets
+	Deprecations []string `toml:"-"`
+
+	// path is the file the config was loaded from
+	path string
 }
 
 func LoadConfig(path string) (*Config, error) {
 	var cfg Config
-	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+	unknown, deprecations, err := tomlfile.Decode(path, &cfg, deprecatedKeys...)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
-
+	if err := tomlfile.CheckSchemaVersion(cfg.SchemaVersion, SchemaVersion); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.Deprecations = deprecations
 	if cfg.Name == "" {
 		return nil, fmt.Errorf("config.name is required")
 	}
+	cfg.path = path
+
+	if err := cfg.Settings.applyDefaults(); err != nil {
+		return nil, fmt.Errorf("config.settings: %w", err)
+	}
+	if err := cfg.checkHosts(); err != nil {
+		return nil, fmt.Errorf("config.%w", err)
+	}
+	if err := cfg.checkEnv(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	switch cfg.Settings.Strictness {
+	case StrictnessStrict:
+		if len(unknown) > 0 {
+			return nil, fmt.Errorf("%s", unknown[0].In(path))
+		}
+	case StrictnessWarn:
+		for _, key := range unknown {
+			cfg.Warnings = append(cfg.Warnings, key.In(path))
+		}
+	}
 
 	return &cfg, nil
 }
 
+// checkEnv rejects env keys, top level or per host, that aren't variable
+// names
+func (c *Config) checkEnv() error {
+	if err := tomlfile.CheckEnvNames("env", c.Env); err != nil {
+		return err
+	}
+	for key, override := range c.Hosts {
+		if err := tomlfile.CheckEnvNames(fmt.Sprintf("hosts.%q.env", key), override.Env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VendoredManifest is the path of the config's vendored manifest, or "" if
+// it uses the global one
+func (c *Config) VendoredManifest() string {
+	if c.Manifest == "" || filepath.IsAbs(c.Manifest) {
+		return c.Manifest
+	}
+	return filepath.Join(filepath.Dir(c.path), c.Manifest)
+}
+
 func (c *Config) Save(path string) error {
 	f, err := os.Create(path)
 	if err != nil {
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// packageLine matches `name = "version"` with the name bare or quoted
+var packageLine = regexp.MustCompile(`^(\s*"?)([A-Za-z0-9_.-]+)("?\s*=\s*)"[^"]*"(.*)$`)
+
+// SetPackageVersion changes one package's version in the config file at
+// path in place, so comments and layout survive. The package must already
+// be listed under [packages].
+func SetPackageVersion(path string, name string, version string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	table := ""
+	found := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			table = strings.Trim(trimmed, "[] ")
+			continue
+		}
+		if table != "packages" {
+			continue
+		}
+		m := packageLine.FindStringSubmatch(line)
+		if m == nil || m[2] != name {
+			continue
+		}
+		lines[i] = fmt.Sprintf(`%s%s%s"%s"%s`, m[1], m[2], m[3], version, m[4])
+		found = true
+		break
+	}
+	if !found {
+		return fmt.Errorf("package %s is not listed under [packages] in %s", name, path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), info.Mode())
+}
+
+// SetManifest points the config file at path to a vendored manifest, adding
+// or replacing its top-level manifest key in place
+func SetManifest(path string, manifest string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	line := fmt.Sprintf("manifest = %q", manifest)
+	lines := strings.Split(string(data), "\n")
+	insert := len(lines)
+	for i, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		if strings.HasPrefix(trimmed, "[") {
+			insert = i
+			break
+		}
+		if key, _, ok := strings.Cut(trimmed, "="); ok && strings.TrimSpace(key) == "manifest" {
+			lines[i] = line
+			insert = -1
+			break
+		}
+	}
+	if insert >= 0 {
+		// Keep a blank line between the top-level keys and the first table
+		for insert > 0 && strings.TrimSpace(lines[insert-1]) == "" {
+			insert--
+		}
+		lines = slices.Insert(lines, insert, line)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), info.Mode())
+}
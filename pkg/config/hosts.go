@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// tagPrefix marks a hosts key that selects by tag rather than hostname
+const tagPrefix = "tag:"
+
+// HostOverride adds to the config on the machines its key selects
+type HostOverride struct {
+	Packages    map[string]string `toml:"packages"`
+	Env         map[string]string `toml:"env"`
+	PathPrepend []string          `toml:"path_prepend"`
+	PathAppend  []string          `toml:"path_append"`
+}
+
+// ApplyHosts merges in the [hosts] sections that match this machine: keys
+// are a glob matched against the hostname, or its first label, or
+// "tag:<name>" matched against tags. Matching sections apply in key order,
+// so where two set the same package or variable the later key wins. It
+// returns the keys that matched.
+func (c *Config) ApplyHosts(hostname string, tags []string) []string {
+	keys := slices.Sorted(maps.Keys(c.Hosts))
+	short, _, _ := strings.Cut(hostname, ".")
+
+	var matched []string
+	for _, key := range keys {
+		if tag, ok := strings.CutPrefix(key, tagPrefix); ok {
+			if !slices.Contains(tags, tag) {
+				continue
+			}
+		} else if !globMatch(key, hostname) && !globMatch(key, short) {
+			continue
+		}
+		matched = append(matched, key)
+
+		override := c.Hosts[key]
+		if c.Packages == nil {
+			c.Packages = make(map[string]string)
+		}
+		maps.Copy(c.Packages, override.Packages)
+		if c.Env == nil && len(override.Env) > 0 {
+			c.Env = make(map[string]string)
+		}
+		maps.Copy(c.Env, override.Env)
+		c.PathPrepend = append(c.PathPrepend, override.PathPrepend...)
+		c.PathAppend = append(c.PathAppend, override.PathAppend...)
+	}
+	return matched
+}
+
+// checkHosts rejects hostname globs path.Match can't parse, which would
+// otherwise never match without saying why
+func (c *Config) checkHosts() error {
+	for key := range c.Hosts {
+		if strings.HasPrefix(key, tagPrefix) {
+			continue
+		}
+		if _, err := path.Match(key, ""); err != nil {
+			return fmt.Errorf("hosts.%q: bad pattern", key)
+		}
+	}
+	return nil
+}
+
+func globMatch(pattern string, name string) bool {
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
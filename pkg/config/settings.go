@@ -0,0 +1,145 @@
+package config
+
+import "fmt"
+
+// Settings tune how a config is applied. Anything left out of the
+// [settings] table gets the default documented on its field.
+type Settings struct {
+	// LinkMode is how commands are exposed in the profile bin dir:
+	// "symlink" (default) or "shim" for per-directory version selection
+	LinkMode string `toml:"link_mode" enum:"symlink,shim"`
+
+	// Strictness is "lenient", "warn" (default) or "strict".
+	// Lenient ignores unknown keys, warn reports them, and strict fails on
+	// them and on commands shadowed earlier in PATH.
+	Strictness string `toml:"strictness" enum:"lenient,warn,strict"`
+
+	// Parallelism is how many packages are downloaded and extracted at once, default 4
+	Parallelism int `toml:"parallelism"`
+
+	// Sandbox runs packages that declare a [sandbox] table in the manifest
+	// under bubblewrap (Linux) or sandbox-exec (macOS), default false
+	Sandbox bool `toml:"sandbox"`
+
+	// KeepVersions is how many versions of each package gc keeps in the store, default 1
+	KeepVersions int `toml:"keep_versions"`
+
+	// ChecksumDB is the URL of a checksum database every download must be
+	// listed in with a matching sha256, so a tampered manifest alone can't
+	// bring in a different binary. Unset by default.
+	ChecksumDB string `toml:"checksum_db"`
+
+	// Gatekeeper is what macOS installs do so tools run without "cannot be
+	// opened" dialogs: "unquarantine" (default) strips the quarantine
+	// attribute, "sign" also ad-hoc signs the binaries, "off" does neither
+	Gatekeeper string `toml:"gatekeeper" enum:"unquarantine,sign,off"`
+
+	// DuplicateBinaries is what happens when packages in the config ship a
+	// binary of the same name: "warn" (default) links the last package's in
+	// name order and says so, "error" fails unless Providers picks one
+	DuplicateBinaries string `toml:"duplicate_binaries" enum:"warn,error"`
+
+	// Providers picks the package that exposes a binary several packages
+	// ship, e.g. [settings.providers] vim = "neovim"
+	Providers map[string]string `toml:"providers"`
+
+	// Progress is how concurrent package output is laid out: "grouped"
+	// (default) prints each package's lines as one block once it is linked,
+	// "prefixed" streams lines as they happen, prefixed with the package name
+	Progress string `toml:"progress" enum:"grouped,prefixed"`
+
+	// Output is the style of status lines: "fancy" with emoji, "plain" or
+	// "ascii". Left empty it is picked from the terminal and locale.
+	Output string `toml:"output" enum:"fancy,plain,ascii"`
+
+	// CABundle is a PEM file of CA certificates downloads trust on top of
+	// the system's, for proxies that intercept TLS. $VARS are expanded.
+	CABundle string `toml:"ca_bundle"`
+}
+
+const (
+	LinkModeSymlink = "symlink"
+	LinkModeShim    = "shim"
+
+	StrictnessLenient = "lenient"
+	StrictnessWarn    = "warn"
+	StrictnessStrict  = "strict"
+
+	GatekeeperUnquarantine = "unquarantine"
+	GatekeeperSign         = "sign"
+	GatekeeperOff          = "off"
+
+	DuplicateBinariesWarn  = "warn"
+	DuplicateBinariesError = "error"
+
+	ProgressGrouped  = "grouped"
+	ProgressPrefixed = "prefixed"
+
+	OutputFancy = "fancy"
+	OutputPlain = "plain"
+	OutputASCII = "ascii"
+)
+
+func (s *Settings) applyDefaults() error {
+	switch s.LinkMode {
+	case "":
+		s.LinkMode = LinkModeSymlink
+	case LinkModeSymlink, LinkModeShim:
+	default:
+		return fmt.Errorf("link_mode must be %q or %q, got %q", LinkModeSymlink, LinkModeShim, s.LinkMode)
+	}
+
+	switch s.Strictness {
+	case "":
+		s.Strictness = StrictnessWarn
+	case StrictnessLenient, StrictnessWarn, StrictnessStrict:
+	default:
+		return fmt.Errorf("strictness must be %q, %q or %q, got %q", StrictnessLenient, StrictnessWarn, StrictnessStrict, s.Strictness)
+	}
+
+	switch {
+	case s.Parallelism == 0:
+		s.Parallelism = 4
+	case s.Parallelism < 0:
+		return fmt.Errorf("parallelism must be at least 1, got %d", s.Parallelism)
+	}
+
+	switch {
+	case s.KeepVersions == 0:
+		s.KeepVersions = 1
+	case s.KeepVersions < 0:
+		return fmt.Errorf("keep_versions must be at least 1, got %d", s.KeepVersions)
+	}
+
+	switch s.Gatekeeper {
+	case "":
+		s.Gatekeeper = GatekeeperUnquarantine
+	case GatekeeperUnquarantine, GatekeeperSign, GatekeeperOff:
+	default:
+		return fmt.Errorf("gatekeeper must be %q, %q or %q, got %q", GatekeeperUnquarantine, GatekeeperSign, GatekeeperOff, s.Gatekeeper)
+	}
+
+	switch s.DuplicateBinaries {
+	case "":
+		s.DuplicateBinaries = DuplicateBinariesWarn
+	case DuplicateBinariesWarn, DuplicateBinariesError:
+	default:
+		return fmt.Errorf("duplicate_binaries must be %q or %q, got %q", DuplicateBinariesWarn, DuplicateBinariesError, s.DuplicateBinaries)
+	}
+
+	switch s.Progress {
+	case "":
+		s.Progress = ProgressGrouped
+	case ProgressGrouped, ProgressPrefixed:
+	default:
+		return fmt.Errorf("progress must be %q or %q, got %q", ProgressGrouped, ProgressPrefixed, s.Progress)
+	}
+
+	switch s.Output {
+	case "", OutputFancy, OutputPlain, OutputASCII:
+	default:
+		return fmt.Errorf("output must be %q, %q or %q, got %q", OutputFancy, OutputPlain, OutputASCII, s.Output)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+	"github.com/crbroughton/pkg-exploration/pkg/repository"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+)
+
+// Engine applies a config: it downloads, verifies and extracts packages with
+// bounded concurrency, links them in name order so output and symlink
+// conflicts resolve the same way on every run, and unlinks what was dropped.
+type Engine struct {
+	BaseDir  string
+	Manifest *manifest.Manifest
+	Repo     *repository.HttpRepository
+	Store    *store.Store
+	Profile  *profile.Profile
+	Observer Observer
+
+	// Previous is the state from the last switch, used to verify cached
+	// downloads and to find packages dropped from the config
+	Previous *state.State
+	// Refresh names packages whose cached downloads are thrown away first
+	Refresh map[string]bool
+	// GC deletes store entries of dropped packages as well as unlinking them
+	GC bool
+	// Broken lists the commands the last Apply linked that won't run
+	Broken []BrokenLink
+	// Downloaded is how many bytes the last Apply downloaded per package
+	Downloaded map[string]int64
+
+	// checksumDB is the config's checksum database, if any
+	checksumDB string
+	// gatekeeper is the config's macOS gatekeeper setting
+	gatekeeper string
+}
+
+// PackageError is a failure attributable to one package
+type PackageError struct {
+	Name string
+	Err  error
+}
+
+func (e *PackageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+func (e *PackageError) Unwrap() error {
+	return e.Err
+}
+
+func emptyState() *state.State {
+	return &state.State{Packages: make(map[string]state.PackageState)}
+}
+
+// Apply installs and links every package in cfg and returns the resulting
+// state. The caller decides whether and where to save it.
+func (e *Engine) Apply(ctx context.Context, cfg *config.Config, configPath string) (*state.State, error) {
+	if e.Observer == nil {
+		e.Observer = NopObserver{}
+	}
+	if e.Previous == nil {
+		e.Previous = emptyState()
+	}
+
+	e.checksumDB = cfg.Settings.ChecksumDB
+	e.gatekeeper = cfg.Settings.Gatekeeper
+	e.Broken = nil
+	e.Downloaded = make(map[string]int64)
+
+	applied := &state.State{
+		Environment: cfg.Name,
+		Config:      configPath,
+		Packages:    make(map[string]state.PackageState),
+	}
+
+	jobs, err := e.resolve(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve packages: %w", err)
+	}
+	if err := e.preflight(ctx, jobs); err != nil {
+		return nil, fmt.Errorf("not enough disk space: %w", err)
+	}
+
+	// On the first failure cancel the rest, and let them stop before
+	// returning so the caller can safely remove what they write to
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		wait(jobs)
+	}()
+	e.start(ctx, jobs, cfg.Settings.Parallelism)
+
+	// Link in name order as each package becomes ready
+	for _, j := range jobs {
+		<-j.done
+		if j.err != nil {
+			e.Observer.OnError(j.name, j.err)
+			return nil, &PackageError{Name: j.name, Err: j.err}
+		}
+
+		// Do the symlinking stuff
+		if err := e.link(cfg, j); err != nil {
+			err = fmt.Errorf("link failed: %w", err)
+			e.Observer.OnError(j.name, err)
+			return nil, &PackageError{Name: j.name, Err: err}
+		}
+		e.Observer.OnLinked(j.name, j.links)
+		e.Broken = append(e.Broken, e.verifyLinks(j)...)
+		if j.downloaded > 0 {
+			e.Downloaded[j.name] = j.downloaded
+		}
+
+		applied.Packages[j.name] = j.state
+	}
+
+	if err := e.reconcile(applied); err != nil {
+		return nil, err
+	}
+
+	applied.AppliedAt = time.Now()
+	return applied, nil
+}
+
+// link exposes a job's binaries in the profile the way the config asks:
+// sandboxed, as shims or as plain symlinks, then wrapped
+func (e *Engine) link(cfg *config.Config, j *job) error {
+	var err error
+	if sandbox := j.pkgDef.Sandbox; cfg.Settings.Sandbox && sandbox != nil {
+		spec := profile.SandboxSpec{Write: sandbox.Write, Network: sandbox.Network}
+		err = e.Profile.Sandbox(j.storePath, j.links, spec)
+	} else if cfg.Settings.LinkMode == config.LinkModeShim {
+		err = e.Profile.Shim(j.name, e.Store.Root(), j.storePath, j.links)
+	} else {
+		err = e.Profile.Link(j.storePath, j.links)
+	}
+	for _, binary := range j.links {
+		if layers := cfg.Wrappers[binary]; err == nil && len(layers) > 0 {
+			err = e.Profile.Wrap(j.storePath, binary, layers)
+		}
+	}
+	return err
+}
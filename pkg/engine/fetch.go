@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/repository"
+)
+
+// Fetched is a download Fetch put in place
+type Fetched struct {
+	Name    string
+	Version string
+	Path    string
+	SHA256  string
+}
+
+// Fetch downloads the artifacts cfg's packages need on platform into dir,
+// named as the cache names them, without installing anything. It lets a
+// machine prepare downloads for another, e.g. a Raspberry Pi or a CI
+// runner, whose cache the files can be copied into.
+func (e *Engine) Fetch(ctx context.Context, cfg *config.Config, platform string, dir string) ([]Fetched, error) {
+	if e.Observer == nil {
+		e.Observer = NopObserver{}
+	}
+	e.checksumDB = cfg.Settings.ChecksumDB
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(cfg.Packages))
+	for name := range cfg.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Every URL first, so an unsupported platform fails before downloading
+	urls := make(map[string]string, len(names))
+	for _, name := range names {
+		url, err := ResolveURL(ctx, e.Repo, e.Manifest, name, cfg.Packages[name], platform)
+		if err != nil {
+			return nil, &PackageError{Name: name, Err: err}
+		}
+		urls[name] = url
+	}
+
+	var fetched []Fetched
+	for _, name := range names {
+		version, url := cfg.Packages[name], urls[name]
+
+		e.Observer.OnPackageStart(name, version)
+		dest := filepath.Join(dir, CacheName(name, version, url))
+		progress := func(done int64, total int64) {
+			e.Observer.OnDownloadProgress(name, done, total)
+		}
+		if err := e.Repo.DownloadFileWithProgress(ctx, url, dest, progress); err != nil {
+			err = fmt.Errorf("download failed: %w", err)
+			e.Observer.OnError(name, err)
+			return fetched, &PackageError{Name: name, Err: err}
+		}
+		e.Observer.OnDownloaded(name)
+
+		digest, err := repository.Digest(dest)
+		if err != nil {
+			return fetched, &PackageError{Name: name, Err: err}
+		}
+		if e.checksumDB != "" {
+			if err := e.verifyChecksum(ctx, name, version, platform, digest); err != nil {
+				os.Remove(dest)
+				e.Observer.OnError(name, err)
+				return fetched, &PackageError{Name: name, Err: err}
+			}
+		}
+
+		fetched = append(fetched, Fetched{Name: name, Version: version, Path: dest, SHA256: digest})
+	}
+	return fetched, nil
+}
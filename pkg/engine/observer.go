@@ -0,0 +1,37 @@
+package engine
+
+// Observer receives progress from Apply so a frontend can render it however
+// it likes. Download and install events come from worker goroutines and
+// interleave between packages; link, unlink, remove and error events come
+// from the goroutine that called Apply, in package name order.
+type Observer interface {
+	OnPackageStart(name string, version string)
+	// OnCacheInvalidated is called when a cached download is thrown away,
+	// with a nil reason when the caller asked for a refresh
+	OnCacheInvalidated(name string, reason error)
+	// OnDownloadProgress reports bytes written so far, total is -1 when unknown
+	OnDownloadProgress(name string, done int64, total int64)
+	OnDownloaded(name string)
+	OnInstallStart(name string)
+	OnInstalled(name string)
+	OnLinked(name string, binaries []string)
+	// OnUnlinked is called for binaries a kept package stopped providing
+	OnUnlinked(name string, binaries []string)
+	// OnRemoved is called for a package dropped from the config
+	OnRemoved(name string, version string, fromStore bool)
+	OnError(name string, err error)
+}
+
+// NopObserver ignores every event, embed it to implement only what you need
+type NopObserver struct{}
+
+func (NopObserver) OnPackageStart(name string, version string)              {}
+func (NopObserver) OnCacheInvalidated(name string, reason error)            {}
+func (NopObserver) OnDownloadProgress(name string, done int64, total int64) {}
+func (NopObserver) OnDownloaded(name string)                                {}
+func (NopObserver) OnInstallStart(name string)                              {}
+func (NopObserver) OnInstalled(name string)                                 {}
+func (NopObserver) OnLinked(name string, binaries []string)                 {}
+func (NopObserver) OnUnlinked(name string, binaries []string)               {}
+func (NopObserver) OnRemoved(name string, version string, fromStore bool)   {}
+func (NopObserver) OnError(name string, err error)                          {}
@@ -0,0 +1,353 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/repository"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+)
+
+// job is one package moving through the install pipeline
+type job struct {
+	name    string
+	version string
+	url     string
+	pkgDef  *manifest.PackageDefinition
+	// links are the binaries this package exposes, those it ships that no
+	// other package in the config owns
+	links []string
+
+	cachePath string
+	storePath string
+	digest    string
+	state     state.PackageState
+	// downloaded is the size of the artifact if it wasn't already cached
+	downloaded int64
+
+	err  error
+	done chan struct{}
+}
+
+// resolve builds a job per package, sorted by name, failing on the first
+// package the manifest can't provide before anything is downloaded
+func (e *Engine) resolve(ctx context.Context, cfg *config.Config) ([]*job, error) {
+	packages := cfg.Packages
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := e.Manifest.CheckConflicts(names); err != nil {
+		return nil, err
+	}
+	if err := e.Manifest.CheckRequires(names); err != nil {
+		return nil, err
+	}
+
+	owners, shared, err := e.Manifest.BinaryOwners(names, cfg.Settings.Providers)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Settings.DuplicateBinaries == config.DuplicateBinariesError {
+		var undecided []string
+		for binary, providers := range shared {
+			if _, ok := cfg.Settings.Providers[binary]; !ok {
+				undecided = append(undecided, fmt.Sprintf("%s (%s)", binary, strings.Join(providers, ", ")))
+			}
+		}
+		if len(undecided) > 0 {
+			sort.Strings(undecided)
+			return nil, fmt.Errorf("binaries shipped by more than one package, pick one with [settings.providers]: %s", strings.Join(undecided, "; "))
+		}
+	}
+
+	jobs := make([]*job, 0, len(names))
+	for _, name := range names {
+		version := packages[name]
+
+		url, err := e.url(ctx, name, version)
+		if err != nil {
+			return nil, fmt.Errorf("%s@%s: %w", name, version, err)
+		}
+		pkgDef, err := e.Manifest.GetPackage(name)
+		if err != nil {
+			return nil, err
+		}
+
+		var links []string
+		for _, binary := range pkgDef.Binaries.Names {
+			if owners[binary] == name {
+				links = append(links, binary)
+			}
+		}
+
+		jobs = append(jobs, &job{
+			name:      name,
+			version:   version,
+			url:       url,
+			pkgDef:    pkgDef,
+			links:     links,
+			cachePath: e.cachePath(name, version, url),
+			done:      make(chan struct{}),
+		})
+	}
+	return jobs, nil
+}
+
+// cachePath is where a package's download is kept
+func (e *Engine) cachePath(name string, version string, url string) string {
+	return filepath.Join(e.BaseDir, "cache", CacheName(name, version, url))
+}
+
+// CacheName is the file name a package's download is cached under
+func CacheName(name string, version string, url string) string {
+	return fmt.Sprintf("%s-%s-%s", name, version, filepath.Base(url))
+}
+
+// start runs the jobs on at most parallelism workers. Each job's done
+// channel closes when it finishes, successfully or not.
+func (e *Engine) start(ctx context.Context, jobs []*job, parallelism int) {
+	slots := make(chan struct{}, parallelism)
+	for _, j := range jobs {
+		go func(j *job) {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			defer close(j.done)
+
+			// Jobs still queued when the run is cancelled never start
+			if j.err = ctx.Err(); j.err != nil {
+				return
+			}
+			j.err = e.install(ctx, j)
+		}(j)
+	}
+}
+
+// wait blocks until every job has finished, so none is still writing to
+// the store or cache once the caller returns
+func wait(jobs []*job) {
+	for _, j := range jobs {
+		<-j.done
+	}
+}
+
+func (e *Engine) install(ctx context.Context, j *job) error {
+	e.Observer.OnPackageStart(j.name, j.version)
+
+	if e.Refresh[j.name] {
+		if err := e.invalidate(j); err != nil {
+			return err
+		}
+		e.Observer.OnCacheInvalidated(j.name, nil)
+	}
+
+	_, statErr := os.Stat(j.cachePath)
+	cached := statErr == nil
+
+	var err error
+	if !cached && e.Store.Installed(j.name, j.version) {
+		// A version kept in the store (see keep_versions) is reused as is,
+		// even once its download has been cleaned out of the cache
+		j.storePath = e.Store.Path(j.name, j.version)
+		if previous, ok := e.Previous.Packages[j.name]; ok && previous.URL == j.url {
+			j.digest = previous.SHA256
+		}
+	} else {
+		err = e.fetch(ctx, j)
+	}
+	if err != nil && cached {
+		// A cached artifact that won't verify or extract is most likely a
+		// truncated or corrupted download, so give it one fresh attempt
+		e.Observer.OnCacheInvalidated(j.name, err)
+		if err := e.invalidate(j); err != nil {
+			return err
+		}
+		err = e.fetch(ctx, j)
+	}
+	if err != nil {
+		return err
+	}
+
+	j.state = state.PackageState{
+		Version:   j.version,
+		URL:       j.url,
+		SHA256:    j.digest,
+		Manifest:  e.Manifest.Source(j.name),
+		StorePath: j.storePath,
+		Binaries:  j.links,
+	}
+
+	if j.pkgDef.UsesDataDir() {
+		j.state.DataDir = e.dataDir(j.name)
+		if err := os.MkdirAll(j.state.DataDir, 0755); err != nil {
+			return fmt.Errorf("failed to create data dir: %w", err)
+		}
+	}
+	j.state.Env = j.pkgDef.ExpandEnv(j.storePath, e.dataDir(j.name))
+	j.state.LibDir, j.state.CertFile = j.pkgDef.LibraryPaths(j.storePath)
+	if info, err := os.Stat(j.cachePath); err == nil {
+		j.state.DownloadedAt = info.ModTime()
+	}
+	return nil
+}
+
+// fetch downloads, verifies and installs a job's artifact
+func (e *Engine) fetch(ctx context.Context, j *job) error {
+	progress := func(done int64, total int64) {
+		e.Observer.OnDownloadProgress(j.name, done, total)
+	}
+	_, statErr := os.Stat(j.cachePath)
+	if err := e.Repo.DownloadFileWithProgress(ctx, j.url, j.cachePath, progress); err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	if info, err := os.Stat(j.cachePath); err == nil && os.IsNotExist(statErr) {
+		j.downloaded = info.Size()
+	}
+	e.Observer.OnDownloaded(j.name)
+
+	digest, err := repository.Digest(j.cachePath)
+	if err != nil {
+		return err
+	}
+	// The same URL should always serve the same bytes
+	if previous, ok := e.Previous.Packages[j.name]; ok && previous.URL == j.url && previous.SHA256 != "" && previous.SHA256 != digest {
+		return fmt.Errorf("checksum mismatch: expected sha256 %s, got %s", previous.SHA256, digest)
+	}
+	if e.checksumDB != "" {
+		if err := e.verifyChecksum(ctx, j.name, j.version, manifest.Platform(), digest); err != nil {
+			return err
+		}
+	}
+	j.digest = digest
+
+	// Install - pass binary names so it knows what to search for
+	e.Observer.OnInstallStart(j.name)
+	fresh := !e.Store.Installed(j.name, j.version)
+	storePath, err := e.Store.Install(j.name, j.version, j.cachePath, j.pkgDef.Binaries.Names, j.pkgDef.LibraryFiles())
+	if err != nil {
+		return fmt.Errorf("install failed: %w", err)
+	}
+	if fresh && runtime.GOOS == "darwin" {
+		if err := e.gatekeeperFixups(storePath, j.pkgDef.Binaries.Names); err != nil {
+			e.Store.Remove(j.name, j.version)
+			return err
+		}
+	}
+	j.storePath = storePath
+	e.Observer.OnInstalled(j.name)
+	return nil
+}
+
+// dataDir is where a package's env can point tools that install more
+// artifacts, kept outside the immutable store entry so it survives upgrades
+func (e *Engine) dataDir(name string) string {
+	return filepath.Join(e.BaseDir, "data", name)
+}
+
+// invalidate drops a job's cached download and store entry so both are redone
+func (e *Engine) invalidate(j *job) error {
+	if err := os.Remove(j.cachePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return e.Store.Remove(j.name, j.version)
+}
+
+// archiveExpansion is how much bigger an extracted archive is than its
+// download. Archives are only held while extracting, but the temp dir and
+// the compressed file coexist, so this errs generous.
+const archiveExpansion = 4
+
+// preflight fails early when the packages still to fetch won't fit on disk,
+// rather than leaving a half-extracted store entry behind
+func (e *Engine) preflight(ctx context.Context, jobs []*job) error {
+	free, err := store.FreeSpace(e.BaseDir)
+	if err != nil {
+		return nil
+	}
+
+	var required int64
+	for _, j := range jobs {
+		if e.Store.Installed(j.name, j.version) {
+			continue
+		}
+
+		// A cached download only needs room to install; anything else is
+		// asked for its size and needs room for the download as well
+		var size int64
+		if info, err := os.Stat(j.cachePath); err == nil {
+			size = info.Size()
+		} else {
+			size, err = e.Repo.ContentLength(ctx, j.url)
+			if err != nil || size < 0 {
+				continue
+			}
+			required += size
+		}
+
+		if store.IsArchive(j.cachePath) {
+			required += size * archiveExpansion
+		} else {
+			required += size
+		}
+	}
+
+	if uint64(required) > free {
+		return fmt.Errorf("need about %s but only %s is free under %s", FormatBytes(uint64(required)), FormatBytes(free), e.BaseDir)
+	}
+	return nil
+}
+
+// FormatBytes renders a byte count with a binary unit, e.g. 1.5 MiB
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// verifyChecksum checks a download against the checksum database
+func (e *Engine) verifyChecksum(ctx context.Context, name string, version string, platform string, digest string) error {
+	expected, err := e.Repo.LookupChecksum(ctx, e.checksumDB, name, version, platform)
+	if errors.Is(err, repository.ErrNotInChecksumDB) {
+		return fmt.Errorf("%s@%s for %s is %w", name, version, platform, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query checksum database: %w", err)
+	}
+	if expected != digest {
+		return fmt.Errorf("checksum database lists sha256 %s, got %s", expected, digest)
+	}
+	return nil
+}
+
+// gatekeeperFixups makes a fresh macOS store entry runnable as the config asks
+func (e *Engine) gatekeeperFixups(storePath string, binaries []string) error {
+	switch e.gatekeeper {
+	case config.GatekeeperOff:
+		return nil
+	case config.GatekeeperSign:
+		if err := store.Unquarantine(storePath); err != nil {
+			return err
+		}
+		return store.AdhocSign(storePath, binaries)
+	default:
+		return store.Unquarantine(storePath)
+	}
+}
@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+)
+
+// Action is what applying a config would do to one package
+type Action string
+
+const (
+	ActionInstall Action = "install"
+	ActionChange  Action = "change"
+	ActionKeep    Action = "keep"
+	ActionRemove  Action = "remove"
+)
+
+// installedExpansion is a rough ratio of extracted binaries to the archive
+// they came from, used when nothing better is known
+const installedExpansion = 2
+
+// PlanFormat is the version of the plan file format, bumped on any change
+// that older readers would misread
+const PlanFormat = 1
+
+// PlanEntry describes one package's part in a switch. Sizes are in bytes
+// and -1 when unknown.
+type PlanEntry struct {
+	Name            string `json:"name"`
+	Version         string `json:"version,omitempty"`
+	PreviousVersion string `json:"previous_version,omitempty"`
+	URL             string `json:"url,omitempty"`
+	Action          Action `json:"action"`
+	// DownloadSize is 0 when the artifact is already cached
+	DownloadSize int64 `json:"download_size"`
+	// InstalledSize is measured when already in the store, estimated otherwise
+	InstalledSize int64 `json:"installed_size"`
+}
+
+// Plan is what Apply would do, without touching anything. Saved as JSON it
+// is a plan file, which yourpm apply carries out only if nothing it was
+// made from has changed since.
+type Plan struct {
+	Format int `json:"format"`
+	// Config and ConfigSHA256 are the config the plan was made from
+	Config       string `json:"config"`
+	ConfigSHA256 string `json:"config_sha256"`
+	// StateHash is the applied state's Hash when the plan was made
+	StateHash string      `json:"state_hash"`
+	Entries   []PlanEntry `json:"entries"`
+}
+
+// WritePlan saves plan as indented JSON
+func WritePlan(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadPlan loads a plan file, refusing formats it doesn't know
+func ReadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if plan.Format != PlanFormat {
+		return nil, fmt.Errorf("plan format %d is not supported, expected %d", plan.Format, PlanFormat)
+	}
+	return &plan, nil
+}
+
+// Totals sums the known download and installed sizes of the plan
+func (p *Plan) Totals() (download int64, installed int64) {
+	for _, entry := range p.Entries {
+		if entry.Action == ActionRemove {
+			continue
+		}
+		if entry.DownloadSize > 0 {
+			download += entry.DownloadSize
+		}
+		if entry.InstalledSize > 0 {
+			installed += entry.InstalledSize
+		}
+	}
+	return download, installed
+}
+
+// Plan works out what Apply would do for cfg, asking servers for download
+// sizes of anything not already cached
+func (e *Engine) Plan(ctx context.Context, cfg *config.Config) (*Plan, error) {
+	if e.Previous == nil {
+		e.Previous = emptyState()
+	}
+
+	jobs, err := e.resolve(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Format: PlanFormat, StateHash: e.Previous.Hash()}
+	for _, j := range jobs {
+		entry := PlanEntry{Name: j.name, Version: j.version, URL: j.url, Action: ActionInstall}
+
+		if previous, ok := e.Previous.Packages[j.name]; ok {
+			entry.PreviousVersion = previous.Version
+			entry.Action = ActionChange
+			if previous.Version == j.version {
+				entry.Action = ActionKeep
+			}
+		}
+
+		if info, err := os.Stat(j.cachePath); err == nil {
+			entry.DownloadSize = 0
+			entry.InstalledSize = estimateInstalled(info.Size(), j.cachePath)
+		} else if size, err := e.Repo.ContentLength(ctx, j.url); err == nil && size >= 0 {
+			entry.DownloadSize = size
+			entry.InstalledSize = estimateInstalled(size, j.cachePath)
+		} else {
+			entry.DownloadSize = -1
+			entry.InstalledSize = -1
+		}
+
+		if e.Store.Installed(j.name, j.version) {
+			entry.InstalledSize = dirSize(e.Store.Path(j.name, j.version))
+		}
+
+		plan.Entries = append(plan.Entries, entry)
+	}
+
+	var dropped []string
+	for name := range e.Previous.Packages {
+		if _, ok := cfg.Packages[name]; !ok {
+			dropped = append(dropped, name)
+		}
+	}
+	sort.Strings(dropped)
+	for _, name := range dropped {
+		plan.Entries = append(plan.Entries, PlanEntry{
+			Name:            name,
+			PreviousVersion: e.Previous.Packages[name].Version,
+			Action:          ActionRemove,
+		})
+	}
+
+	return plan, nil
+}
+
+// CheckPlan fails if applying cfg now would do something other than plan
+// says: the state moved on, or the manifest resolves a package differently
+func (e *Engine) CheckPlan(ctx context.Context, cfg *config.Config, plan *Plan) error {
+	if e.Previous == nil {
+		e.Previous = emptyState()
+	}
+	if hash := e.Previous.Hash(); hash != plan.StateHash {
+		return fmt.Errorf("the applied environment changed since the plan was made")
+	}
+
+	fresh, err := e.resolve(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	planned := make(map[string]PlanEntry)
+	for _, entry := range plan.Entries {
+		if entry.Action != ActionRemove {
+			planned[entry.Name] = entry
+		}
+	}
+	if len(planned) != len(fresh) {
+		return fmt.Errorf("the plan has %d packages, the config now has %d", len(planned), len(fresh))
+	}
+	for _, j := range fresh {
+		entry, ok := planned[j.name]
+		if !ok {
+			return fmt.Errorf("%s is not in the plan", j.name)
+		}
+		if entry.Version != j.version || entry.URL != j.url {
+			return fmt.Errorf("%s now resolves to %s at %s, the plan has %s at %s", j.name, j.version, j.url, entry.Version, entry.URL)
+		}
+	}
+	return nil
+}
+
+func estimateInstalled(downloadSize int64, cachePath string) int64 {
+	if store.IsArchive(cachePath) {
+		return downloadSize * installedExpansion
+	}
+	return downloadSize
+}
+
+func dirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
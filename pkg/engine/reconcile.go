@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/crbroughton/pkg-exploration/pkg/guard"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+)
+
+// reconcile unlinks whatever the previous switch exposed that the new config
+// no longer does: dropped packages, and binaries a kept package stopped shipping
+func (e *Engine) reconcile(applied *state.State) error {
+	names := make([]string, 0, len(e.Previous.Packages))
+	for name := range e.Previous.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Every binary the new state exposes, looked up once rather than
+	// through Owner, which scans the whole state each time
+	exposed := make(map[string]bool)
+	for _, pkg := range applied.Packages {
+		for _, binary := range pkg.Binaries {
+			exposed[binary] = true
+		}
+	}
+
+	for _, name := range names {
+		old := e.Previous.Packages[name]
+		_, kept := applied.Packages[name]
+
+		var stale []string
+		for _, binary := range old.Binaries {
+			if exposed[binary] {
+				continue
+			}
+			stale = append(stale, binary)
+		}
+
+		removed, err := e.Profile.Unlink(old.StorePath, stale)
+		if err != nil {
+			return &PackageError{Name: name, Err: fmt.Errorf("failed to unlink: %w", err)}
+		}
+
+		if kept {
+			if len(removed) > 0 {
+				e.Observer.OnUnlinked(name, removed)
+			}
+			continue
+		}
+
+		if e.GC {
+			if err := e.Store.Remove(name, old.Version); err != nil {
+				return &PackageError{Name: name, Err: fmt.Errorf("failed to remove from store: %w", err)}
+			}
+			if old.DataDir != "" {
+				if err := guard.RemoveAll(e.BaseDir, old.DataDir); err != nil {
+					return &PackageError{Name: name, Err: fmt.Errorf("failed to remove data dir: %w", err)}
+				}
+			}
+		}
+		e.Observer.OnRemoved(name, old.Version, e.GC)
+	}
+
+	return nil
+}
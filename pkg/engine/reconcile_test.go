@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/crbroughton/pkg-exploration/pkg/profile"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+)
+
+// BenchmarkReconcile drops a few packages from profiles of growing size.
+// Unlinking is driven by the state, one Readlink per binary of a dropped
+// package, so the profile's size shouldn't show in the time.
+func BenchmarkReconcile(b *testing.B) {
+	for _, kept := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("kept=%d", kept), func(b *testing.B) {
+			benchmarkReconcile(b, kept, 10)
+		})
+	}
+}
+
+func benchmarkReconcile(b *testing.B, kept int, dropped int) {
+	baseDir := b.TempDir()
+	prof := profile.NewProfile(filepath.Join(baseDir, "profiles", "default"))
+	previous := &state.State{Packages: make(map[string]state.PackageState)}
+	applied := &state.State{Packages: make(map[string]state.PackageState)}
+
+	link := func(name string) state.PackageState {
+		pkg := state.PackageState{
+			Version:   "1.0.0",
+			StorePath: filepath.Join(baseDir, "store", name+"-1.0.0"),
+			Binaries:  []string{name, name + "-helper"},
+		}
+		if err := prof.Link(pkg.StorePath, pkg.Binaries); err != nil {
+			b.Fatal(err)
+		}
+		return pkg
+	}
+	for i := 0; i < kept; i++ {
+		name := fmt.Sprintf("kept%04d", i)
+		pkg := link(name)
+		previous.Packages[name] = pkg
+		applied.Packages[name] = pkg
+	}
+
+	e := &Engine{
+		BaseDir:  baseDir,
+		Store:    store.NewStore(filepath.Join(baseDir, "store")),
+		Profile:  prof,
+		Observer: NopObserver{},
+		Previous: previous,
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for d := 0; d < dropped; d++ {
+			name := fmt.Sprintf("dropped%04d", d)
+			previous.Packages[name] = link(name)
+		}
+		b.StartTimer()
+
+		if err := e.reconcile(applied); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+)
+
+// Damaged lists the applied packages whose store entry is gone or missing a
+// binary, say because the store was cleaned by hand
+func (e *Engine) Damaged() []string {
+	var damaged []string
+	for name, pkg := range e.Previous.Packages {
+		if !e.Store.Installed(name, pkg.Version) {
+			damaged = append(damaged, name)
+			continue
+		}
+		for _, binary := range pkg.Binaries {
+			if _, err := os.Stat(filepath.Join(pkg.StorePath, binary)); err != nil {
+				damaged = append(damaged, name)
+				break
+			}
+		}
+	}
+	sort.Strings(damaged)
+	return damaged
+}
+
+// Repair reinstalls the store entries of applied packages from the URL and
+// checksum the state recorded for them, leaving the profile alone. Links and
+// shims point at the same store path, so they work again once it is back.
+func (e *Engine) Repair(ctx context.Context, settings config.Settings, names []string) error {
+	if e.Previous == nil {
+		e.Previous = emptyState()
+	}
+
+	entries := make([]StoreEntry, 0, len(names))
+	for _, name := range names {
+		pkg, ok := e.Previous.Packages[name]
+		if !ok {
+			return fmt.Errorf("%s is not applied", name)
+		}
+		if err := e.Store.Remove(name, pkg.Version); err != nil {
+			return fmt.Errorf("%s: failed to clear damaged store entry: %w", name, err)
+		}
+		entries = append(entries, StoreEntry{Name: name, Version: pkg.Version, URL: pkg.URL, Binaries: pkg.Binaries})
+	}
+	return e.Reinstall(ctx, settings, entries)
+}
+
+// StoreEntry is a package version to put back in the store, and the
+// binaries to look for if the manifest no longer knows the package
+type StoreEntry struct {
+	Name     string
+	Version  string
+	URL      string
+	Binaries []string
+}
+
+// Reinstall downloads and installs store entries without touching the
+// profile. A package the state has applied at the same URL is checked
+// against its recorded checksum.
+func (e *Engine) Reinstall(ctx context.Context, settings config.Settings, entries []StoreEntry) error {
+	if e.Observer == nil {
+		e.Observer = NopObserver{}
+	}
+	if e.Previous == nil {
+		e.Previous = emptyState()
+	}
+	e.checksumDB = settings.ChecksumDB
+	e.gatekeeper = settings.Gatekeeper
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Version < entries[j].Version
+	})
+	jobs := make([]*job, 0, len(entries))
+	for _, entry := range entries {
+		// Fall back to what the caller knows if the manifest dropped the package
+		pkgDef, err := e.Manifest.GetPackage(entry.Name)
+		if err != nil {
+			pkgDef = &manifest.PackageDefinition{Binaries: manifest.BinaryInfo{Names: entry.Binaries}}
+		}
+		jobs = append(jobs, &job{
+			name:      entry.Name,
+			version:   entry.Version,
+			url:       entry.URL,
+			pkgDef:    pkgDef,
+			cachePath: e.cachePath(entry.Name, entry.Version, entry.URL),
+			done:      make(chan struct{}),
+		})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		wait(jobs)
+	}()
+	e.start(ctx, jobs, settings.Parallelism)
+	for _, j := range jobs {
+		<-j.done
+		if j.err != nil {
+			e.Observer.OnError(j.name, j.err)
+			return &PackageError{Name: j.name, Err: j.err}
+		}
+	}
+	return nil
+}
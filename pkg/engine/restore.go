@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/repository"
+	"github.com/crbroughton/pkg-exploration/pkg/state"
+)
+
+// Restore rebuilds the profile from the applied state without touching the
+// network: a store entry that is gone is extracted again from its cached
+// download, then every package is linked the way cfg asks. It returns the
+// packages it couldn't restore because neither the store nor the cache
+// still has them.
+func (e *Engine) Restore(cfg *config.Config) ([]string, error) {
+	if e.Previous == nil {
+		e.Previous = emptyState()
+	}
+	return e.restore(cfg, e.Previous)
+}
+
+// Use flips the profile from the applied state to target, another state
+// applied before, the way Restore does: without touching the network. If
+// any package of target is in neither the store nor the download cache, the
+// profile is left alone and those packages are returned.
+func (e *Engine) Use(cfg *config.Config, target *state.State) ([]string, error) {
+	if e.Previous == nil {
+		e.Previous = emptyState()
+	}
+
+	var lost []string
+	for name, pkg := range target.Packages {
+		if e.Store.Installed(name, pkg.Version) {
+			continue
+		}
+		if _, err := os.Stat(e.cachePath(name, pkg.Version, pkg.URL)); err != nil {
+			lost = append(lost, name)
+		}
+	}
+	if len(lost) > 0 {
+		sort.Strings(lost)
+		return lost, nil
+	}
+
+	if _, err := e.restore(cfg, target); err != nil {
+		return nil, err
+	}
+	return nil, e.reconcile(target)
+}
+
+func (e *Engine) restore(cfg *config.Config, target *state.State) ([]string, error) {
+	if e.Observer == nil {
+		e.Observer = NopObserver{}
+	}
+	e.gatekeeper = cfg.Settings.Gatekeeper
+	e.Broken = nil
+
+	names := make([]string, 0, len(target.Packages))
+	for name := range target.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lost []string
+	for _, name := range names {
+		pkg := target.Packages[name]
+		// Fall back to the state's binaries if the manifest dropped the package
+		pkgDef, err := e.Manifest.GetPackage(name)
+		if err != nil {
+			pkgDef = &manifest.PackageDefinition{Binaries: manifest.BinaryInfo{Names: pkg.Binaries}}
+		}
+		j := &job{
+			name:      name,
+			version:   pkg.Version,
+			url:       pkg.URL,
+			pkgDef:    pkgDef,
+			links:     pkg.Binaries,
+			cachePath: e.cachePath(name, pkg.Version, pkg.URL),
+			storePath: e.Store.Path(name, pkg.Version),
+			state:     pkg,
+		}
+		e.Observer.OnPackageStart(name, pkg.Version)
+
+		if !e.Store.Installed(name, pkg.Version) {
+			if _, err := os.Stat(j.cachePath); err != nil {
+				e.Observer.OnError(name, fmt.Errorf("not in the store or the download cache"))
+				lost = append(lost, name)
+				continue
+			}
+			if err := e.extractCached(j, pkg.SHA256); err != nil {
+				e.Observer.OnError(name, err)
+				return lost, &PackageError{Name: name, Err: err}
+			}
+		}
+		if pkg.DataDir != "" {
+			if err := os.MkdirAll(pkg.DataDir, 0755); err != nil {
+				return lost, &PackageError{Name: name, Err: fmt.Errorf("failed to create data dir: %w", err)}
+			}
+		}
+
+		if err := e.link(cfg, j); err != nil {
+			err = fmt.Errorf("link failed: %w", err)
+			e.Observer.OnError(name, err)
+			return lost, &PackageError{Name: name, Err: err}
+		}
+		e.Observer.OnLinked(name, j.links)
+		e.Broken = append(e.Broken, e.verifyLinks(j)...)
+	}
+	return lost, nil
+}
+
+// extractCached installs a job from its cached download, checked against
+// the digest the state recorded. Unlike fetch it never downloads again.
+func (e *Engine) extractCached(j *job, want string) error {
+	digest, err := repository.Digest(j.cachePath)
+	if err != nil {
+		return err
+	}
+	if want != "" && digest != want {
+		return fmt.Errorf("cached download doesn't match: expected sha256 %s, got %s", want, digest)
+	}
+
+	e.Observer.OnInstallStart(j.name)
+	storePath, err := e.Store.Install(j.name, j.version, j.cachePath, j.pkgDef.Binaries.Names, j.pkgDef.LibraryFiles())
+	if err != nil {
+		return fmt.Errorf("install failed: %w", err)
+	}
+	if runtime.GOOS == "darwin" {
+		if err := e.gatekeeperFixups(storePath, j.pkgDef.Binaries.Names); err != nil {
+			e.Store.Remove(j.name, j.version)
+			return err
+		}
+	}
+	j.storePath = storePath
+	e.Observer.OnInstalled(j.name)
+	return nil
+}
@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"context"
+	"errors"
+
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/repository"
+)
+
+// ResolveURL is the download URL of a package version on platform: the
+// manifest's URL, or for a release asset the URL the forge's API gives for it
+func ResolveURL(ctx context.Context, repo *repository.HttpRepository, mfst *manifest.Manifest, name string, version string, platform string) (string, error) {
+	url, err := mfst.GetURLFor(name, version, platform)
+	if !errors.Is(err, manifest.ErrReleaseAsset) {
+		return url, err
+	}
+
+	pkgDef, err := mfst.GetPackage(name)
+	if err != nil {
+		return "", err
+	}
+	asset, _ := pkgDef.Asset(version, platform)
+	return repo.ReleaseAsset(ctx, pkgDef.Source, pkgDef.Host, pkgDef.Repo, pkgDef.ReleaseTag(version), asset)
+}
+
+// url is ResolveURL for this platform, reusing the URL the previous switch
+// recorded for the same version so release assets aren't looked up again
+func (e *Engine) url(ctx context.Context, name string, version string) (string, error) {
+	if e.Previous != nil {
+		if pkg, ok := e.Previous.Packages[name]; ok && pkg.Version == version && pkg.URL != "" {
+			if _, err := e.Manifest.GetURL(name, version); errors.Is(err, manifest.ErrReleaseAsset) {
+				return pkg.URL, nil
+			}
+		}
+	}
+	return ResolveURL(ctx, e.Repo, e.Manifest, name, version, manifest.Platform())
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/crbroughton/pkg-exploration/pkg/store"
+)
+
+// BrokenLink is a command Apply exposed that won't run, and why
+type BrokenLink struct {
+	Package string
+	Binary  string
+	Cause   string
+}
+
+// verifyLinks checks each command a job exposes resolves to an executable
+// in its store entry, whichever way it was linked
+func (e *Engine) verifyLinks(j *job) []BrokenLink {
+	var broken []BrokenLink
+	for _, binary := range j.links {
+		if cause := brokenCause(j, binary); cause != "" {
+			broken = append(broken, BrokenLink{Package: j.name, Binary: binary, Cause: cause})
+		}
+	}
+	return broken
+}
+
+func brokenCause(j *job, binary string) string {
+	path := filepath.Join(j.storePath, binary)
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return fmt.Sprintf("%s is a directory", path)
+	case err == nil && info.Mode()&0111 == 0:
+		return fmt.Sprintf("%s is not executable", path)
+	case err == nil:
+		return ""
+	}
+
+	if target, lerr := os.Readlink(path); lerr == nil {
+		return fmt.Sprintf("%s is a symlink to %s, which doesn't exist; the archive linked it relative to a dir that wasn't kept", path, target)
+	}
+	if installed := store.SingleBinaryName(j.name, j.pkgDef.Binaries.Names); !store.IsArchive(j.cachePath) && binary != installed {
+		return fmt.Sprintf("the download is a single file, installed as %s, but the manifest names the binary %s", installed, binary)
+	}
+	if !os.IsNotExist(err) {
+		return err.Error()
+	}
+	return fmt.Sprintf("%s is missing from the store entry; check the manifest's binary name, or run yourpm repair %s", path, j.name)
+}
@@ -0,0 +1,82 @@
+// Package events keeps a log of what yourpm changed on this machine, one
+// JSON object per line, for auditing with yourpm report
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Actions an event records
+const (
+	Installed = "installed"
+	Updated   = "updated"
+	Removed   = "removed"
+	Pruned    = "pruned"
+)
+
+// Event is one package changing
+type Event struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Package string    `json:"package"`
+	Version string    `json:"version"`
+	// From is the version an update replaced
+	From string `json:"from,omitempty"`
+	// Bytes is what was downloaded to install or update the package, or
+	// the disk space pruning it freed
+	Bytes int64 `json:"bytes,omitempty"`
+}
+
+// Append adds events to the log at path
+func Append(path string, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// Read returns the events logged at path since the given time, oldest
+// first. A missing log has no events, and a line that doesn't parse, like
+// one cut short by a crash, is skipped.
+func Read(path string, since time.Time) ([]Event, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if !event.Time.Before(since) {
+			events = append(events, event)
+		}
+	}
+	return events, scanner.Err()
+}
@@ -0,0 +1,137 @@
+// Package generation keeps a numbered history of applied environments
+package generation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Generation is one applied environment
+type Generation struct {
+	Number        int               `toml:"number"`
+	CreatedAt     time.Time         `toml:"created_at"`
+	Environment   string            `toml:"environment"`
+	Config        string            `toml:"config"`
+	ConfigHash    string            `toml:"config_hash"`
+	YourpmVersion string            `toml:"yourpm_version"`
+	Message       string            `toml:"message,omitempty"`
+	Packages      map[string]string `toml:"packages"`
+}
+
+// History is a dir of generations, one <number>.toml file each
+type History struct {
+	dir string
+}
+
+func NewHistory(dir string) *History {
+	return &History{dir: dir}
+}
+
+// List returns every generation, oldest first
+func (h *History) List() ([]Generation, error) {
+	files, err := os.ReadDir(h.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var generations []Generation
+	for _, file := range files {
+		number, err := strconv.Atoi(strings.TrimSuffix(file.Name(), ".toml"))
+		if err != nil || !strings.HasSuffix(file.Name(), ".toml") {
+			continue
+		}
+		g, err := h.Get(number)
+		if err != nil {
+			return nil, err
+		}
+		generations = append(generations, *g)
+	}
+
+	sort.Slice(generations, func(i, j int) bool {
+		return generations[i].Number < generations[j].Number
+	})
+	return generations, nil
+}
+
+// Get reads one generation
+func (h *History) Get(number int) (*Generation, error) {
+	var g Generation
+	if _, err := toml.DecodeFile(h.path(number), &g); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("generation %d does not exist", number)
+		}
+		return nil, fmt.Errorf("failed to parse generation %d: %w", number, err)
+	}
+	return &g, nil
+}
+
+// Record saves g as the next generation and returns its number
+func (h *History) Record(g Generation) (int, error) {
+	generations, err := h.List()
+	if err != nil {
+		return 0, err
+	}
+	g.Number = 1
+	if len(generations) > 0 {
+		g.Number = generations[len(generations)-1].Number + 1
+	}
+
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(h.path(g.Number))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if err := toml.NewEncoder(f).Encode(g); err != nil {
+		return 0, err
+	}
+	return g.Number, nil
+}
+
+func (h *History) path(number int) string {
+	return filepath.Join(h.dir, fmt.Sprintf("%d.toml", number))
+}
+
+// Change is how one package differs between two generations. From or To is
+// empty when the package was added or removed.
+type Change struct {
+	Name string
+	From string
+	To   string
+}
+
+// Diff lists the packages that differ from a to b, by name
+func Diff(a *Generation, b *Generation) []Change {
+	names := make(map[string]bool)
+	for name := range a.Packages {
+		names[name] = true
+	}
+	for name := range b.Packages {
+		names[name] = true
+	}
+
+	var changes []Change
+	for name := range names {
+		from, to := a.Packages[name], b.Packages[name]
+		if from != to {
+			changes = append(changes, Change{Name: name, From: from, To: to})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
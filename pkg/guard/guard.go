@@ -0,0 +1,54 @@
+// Package guard keeps yourpm's deletions inside the dirs it owns, so a
+// corrupted state file or a symlink in the wrong place can't point one at
+// the user's files
+package guard
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Check fails unless path lies under root. Symlinks in both are resolved,
+// except path's last element: removing a symlink removes the link, not
+// what it points at.
+func Check(root string, path string) error {
+	realRoot := resolve(root)
+	realPath := filepath.Join(resolve(filepath.Dir(path)), filepath.Base(path))
+
+	rel, err := filepath.Rel(realRoot, realPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to modify %s, it is outside %s", path, root)
+	}
+	return nil
+}
+
+// Remove is os.Remove for a path that must be under root
+func Remove(root string, path string) error {
+	if err := Check(root, path); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// RemoveAll is os.RemoveAll for a path that must be under root
+func RemoveAll(root string, path string) error {
+	if err := Check(root, path); err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
+// resolve follows the symlinks in path, as far as it exists
+func resolve(path string) string {
+	path = filepath.Clean(path)
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+	return filepath.Join(resolve(parent), filepath.Base(path))
+}
@@ -0,0 +1,63 @@
+package guard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "store", "pkg"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A root reached through a symlink, and links inside the root to
+	// either side of it
+	linkedRoot := filepath.Join(base, "linked")
+	if err := os.Symlink(root, linkedRoot); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "store"), filepath.Join(root, "inner")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		root string
+		path string
+		ok   bool
+	}{
+		{"under root", root, filepath.Join(root, "store", "pkg"), true},
+		{"not yet created", root, filepath.Join(root, "store", "new", "file"), true},
+		{"root itself", root, root, false},
+		{"outside root", root, outside, false},
+		{"sibling with the root as prefix", root, root + "-other", false},
+		{"dot dot traversal", root, filepath.Join(root, "store", "..", "..", "outside"), false},
+		{"unclean dot dot", root, root + "/store/../../outside", false},
+		{"dot dot staying inside", root, filepath.Join(root, "store", "..", "store", "pkg"), true},
+		{"symlinked root", linkedRoot, filepath.Join(linkedRoot, "store", "pkg"), true},
+		{"real path under a symlinked root", linkedRoot, filepath.Join(root, "store", "pkg"), true},
+		{"outside a symlinked root", linkedRoot, outside, false},
+		{"through a link pointing outside", root, filepath.Join(root, "escape", "file"), false},
+		{"the link pointing outside itself", root, filepath.Join(root, "escape"), true},
+		{"through a link staying inside", root, filepath.Join(root, "inner", "pkg"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.root, tt.path)
+			if tt.ok && err != nil {
+				t.Errorf("Check(%s, %s) = %v, want nil", tt.root, tt.path, err)
+			}
+			if !tt.ok && err == nil {
+				t.Errorf("Check(%s, %s) = nil, want an error", tt.root, tt.path)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// Package lint flags risky patterns in a config and the manifest entries it uses
+package lint
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/config"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
+	"github.com/crbroughton/pkg-exploration/pkg/sealed"
+)
+
+// Rule IDs, stable so they can be looked up and referred to
+const (
+	RuleUnpinnedVersion   = "YP001"
+	RuleUnknownPackage    = "YP002"
+	RuleMutableURL        = "YP003"
+	RuleInsecureURL       = "YP004"
+	RulePlaintextSecret   = "YP005"
+	RuleNoChecksumDB      = "YP006"
+	RulePathOverride      = "YP007"
+	RuleSandboxWritesHome = "YP008"
+)
+
+// Finding is one problem, at Key in File
+type Finding struct {
+	Rule    string
+	File    string
+	Key     string
+	Message string
+	// Fixable is set when lint --fix knows how to fix it
+	Fixable bool
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s %s: %s", f.File, f.Rule, f.Key, f.Message)
+}
+
+// unpinned are versions that don't name one release
+var unpinned = map[string]bool{"": true, "latest": true, "*": true, "stable": true}
+
+// Check lints cfg, loaded from configPath, and the manifest entries of its
+// packages. Both should be as read from disk, before sealed values are
+// decrypted, so a sealed value counts as a secret kept safe.
+func Check(cfg *config.Config, configPath string, mfst *manifest.Manifest) []Finding {
+	var findings []Finding
+
+	names := make([]string, 0, len(cfg.Packages))
+	for name := range cfg.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := "packages." + name
+		version := cfg.Packages[name]
+		if unpinned[strings.ToLower(version)] {
+			findings = append(findings, Finding{
+				Rule:    RuleUnpinnedVersion,
+				File:    configPath,
+				Key:     key,
+				Message: fmt.Sprintf("version %q isn't pinned, so the same config can install different binaries", version),
+				Fixable: true,
+			})
+		}
+
+		pkgDef, err := mfst.GetPackage(name)
+		if err != nil {
+			findings = append(findings, Finding{
+				Rule:    RuleUnknownPackage,
+				File:    configPath,
+				Key:     key,
+				Message: "not defined in the manifest",
+			})
+			continue
+		}
+		findings = append(findings, checkPackage(name, pkgDef, mfst.Source(name))...)
+	}
+
+	if cfg.Settings.ChecksumDB == "" {
+		findings = append(findings, Finding{
+			Rule:    RuleNoChecksumDB,
+			File:    configPath,
+			Key:     "settings.checksum_db",
+			Message: "unset, so a first download is trusted as served and only later ones are checked against it",
+		})
+	}
+
+	if _, ok := cfg.Env["PATH"]; ok {
+		findings = append(findings, Finding{
+			Rule:    RulePathOverride,
+			File:    configPath,
+			Key:     "env.PATH",
+			Message: "replaces PATH, including the profile bin dir; use path_prepend or path_append",
+		})
+	}
+
+	return findings
+}
+
+func checkPackage(name string, pkgDef *manifest.PackageDefinition, source string) []Finding {
+	var findings []Finding
+
+	platforms := make([]string, 0, len(pkgDef.URLs))
+	for platform := range pkgDef.URLs {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	for _, platform := range platforms {
+		template := pkgDef.URLs[platform]
+		key := fmt.Sprintf("packages.%s.urls.%s", name, platform)
+		if sealed.IsSealed(template) {
+			continue
+		}
+
+		if !strings.Contains(template, "{version}") {
+			findings = append(findings, Finding{
+				Rule:    RuleMutableURL,
+				File:    source,
+				Key:     key,
+				Message: "has no {version}, so every version downloads whatever the URL serves today",
+			})
+		}
+
+		u, err := url.Parse(template)
+		if err != nil {
+			continue
+		}
+		if u.Scheme == "http" {
+			findings = append(findings, Finding{
+				Rule:    RuleInsecureURL,
+				File:    source,
+				Key:     key,
+				Message: "downloads over plain http",
+			})
+		}
+		if _, hasPassword := u.User.Password(); hasPassword || u.Query().Has("token") {
+			findings = append(findings, Finding{
+				Rule:    RulePlaintextSecret,
+				File:    source,
+				Key:     key,
+				Message: "has credentials in plain text, encrypt the URL with yourpm seal",
+			})
+		}
+	}
+
+	if pkgDef.Sandbox != nil {
+		for _, path := range pkgDef.Sandbox.Write {
+			if strings.TrimSuffix(path, "/") == "{home}" {
+				findings = append(findings, Finding{
+					Rule:    RuleSandboxWritesHome,
+					File:    source,
+					Key:     fmt.Sprintf("packages.%s.sandbox.write", name),
+					Message: "lets the package write all of {home}, name the dirs it needs instead",
+				})
+			}
+		}
+	}
+
+	return findings
+}
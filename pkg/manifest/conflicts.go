@@ -0,0 +1,88 @@
+package manifest
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// CheckConflicts returns an error listing every pair of the given packages
+// that declares a conflict, in either direction
+func (m *Manifest) CheckConflicts(packages []string) error {
+	selected := slices.Clone(packages)
+	sort.Strings(selected)
+
+	var clashes []string
+	for i, a := range selected {
+		for _, b := range selected[i+1:] {
+			if m.conflicts(a, b) || m.conflicts(b, a) {
+				clashes = append(clashes, fmt.Sprintf("%s and %s", a, b))
+			}
+		}
+	}
+
+	if len(clashes) == 0 {
+		return nil
+	}
+	return fmt.Errorf("conflicting packages, remove one of each pair from the config: %s", strings.Join(clashes, "; "))
+}
+
+// CheckRequires returns an error listing every library package one of the
+// given packages requires that isn't among them
+func (m *Manifest) CheckRequires(packages []string) error {
+	selected := slices.Clone(packages)
+	sort.Strings(selected)
+
+	var missing []string
+	for _, name := range selected {
+		for _, required := range m.Packages[name].Requires {
+			if !slices.Contains(selected, required) {
+				missing = append(missing, fmt.Sprintf("%s requires %s", name, required))
+			}
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("missing runtime dependencies, add them to the config: %s", strings.Join(missing, "; "))
+}
+
+func (m *Manifest) conflicts(name string, other string) bool {
+	pkg, ok := m.Packages[name]
+	return ok && slices.Contains(pkg.Conflicts, other)
+}
+
+// BinaryOwners decides which of the given packages exposes each binary they
+// ship. A binary more than one of them ships goes to the package prefer
+// names for it, or else to the last in name order, which is the one that
+// used to end up linked. shared lists the providers of each such binary.
+func (m *Manifest) BinaryOwners(packages []string, prefer map[string]string) (owners map[string]string, shared map[string][]string, err error) {
+	selected := slices.Clone(packages)
+	sort.Strings(selected)
+
+	providers := make(map[string][]string)
+	for _, name := range selected {
+		for _, binary := range m.Packages[name].Binaries.Names {
+			providers[binary] = append(providers[binary], name)
+		}
+	}
+
+	owners = make(map[string]string, len(providers))
+	shared = make(map[string][]string)
+	for binary, names := range providers {
+		owners[binary] = names[len(names)-1]
+		if len(names) > 1 {
+			shared[binary] = names
+		}
+
+		if preferred, ok := prefer[binary]; ok {
+			if !slices.Contains(names, preferred) {
+				return nil, nil, fmt.Errorf("providers.%s is %s, which doesn't provide it, pick one of %s", binary, preferred, strings.Join(names, ", "))
+			}
+			owners[binary] = preferred
+		}
+	}
+	return owners, shared, nil
+}
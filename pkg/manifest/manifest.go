@@ -1,15 +1,40 @@
 package manifest
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"sort"
 	"strings"
 
-	"github.com/BurntSushi/toml"
+	"github.com/crbroughton/pkg-exploration/pkg/tomlfile"
 )
 
+// SchemaVersion is the newest version of the manifest format this yourpm
+// reads. It goes up only when an existing key changes meaning or goes away;
+// new keys don't need it.
+const SchemaVersion = 1
+
+// deprecatedKeys still work but are going away. A renamed key is listed here
+// until the release that removes it.
+var deprecatedKeys []tomlfile.Deprecation
+
 type Manifest struct {
+	// SchemaVersion is the format version the file was written for, 1 if
+	// it doesn't say
+	SchemaVersion int `toml:"schema_version,omitempty"`
+
 	Packages map[string]PackageDefinition `toml:"packages"`
+
+	// Sources maps each package to the manifest file that defined it
+	Sources map[string]string `toml:"-"`
+
+	// Warnings about keys in the file that were not recognised
+	Warnings []string `toml:"-"`
+	// Deprecations warn about deprecated keys the file sets
+	Deprecations []string `toml:"-"`
 }
 
 type PackageDefinition struct {
@@ -17,6 +42,48 @@ type PackageDefinition struct {
 	Description string            `toml:"description"`
 	Binaries    BinaryInfo        `toml:"binaries"`
 	URLs        map[string]string `toml:"urls"`
+
+	// Source is the forge hosting Repo's releases: "github" (default),
+	// "gitlab" or "gitea". Host points gitlab or gitea at a self-hosted
+	// instance, defaulting to gitlab.com and codeberg.org.
+	Source string `toml:"source,omitempty" enum:"github,gitlab,gitea"`
+	Host   string `toml:"host,omitempty"`
+
+	// Assets names the release asset to download per platform, in place of
+	// a URL, for gitlab and gitea sources. It is looked up through the
+	// forge's release API in the release tagged Tag, "v{version}" by
+	// default, with the host's saved token. {version} is replaced in both.
+	Assets map[string]string `toml:"assets"`
+	Tag    string            `toml:"tag,omitempty"`
+
+	// Env is exported by the profile while the package is installed.
+	// Values may use {store} for the package's store entry and {data} for a
+	// writable per-package dir, e.g. GOBIN = "{data}/bin".
+	Env map[string]string `toml:"env"`
+
+	// Sandbox declares what the package needs when sandboxing is enabled
+	Sandbox *SandboxInfo `toml:"sandbox"`
+
+	// Conflicts names packages that can't be installed alongside this one,
+	// usually other providers of the same commands
+	Conflicts []string `toml:"conflicts"`
+
+	// Libraries names shared libraries the package provides to others at
+	// runtime, and Certificates a CA bundle. Both are kept in the store
+	// entry's lib dir, which the profile puts on the dynamic linker's search
+	// path and points SSL_CERT_FILE into.
+	Libraries    []string `toml:"libraries"`
+	Certificates string   `toml:"certificates,omitempty"`
+
+	// Requires names library packages this one needs at runtime
+	Requires []string `toml:"requires"`
+}
+
+// SandboxInfo lists the paths a sandboxed package may write to, which may
+// use {cwd} and {home}, and whether it needs the network
+type SandboxInfo struct {
+	Write   []string `toml:"write"`
+	Network bool     `toml:"network"`
 }
 
 type BinaryInfo struct {
@@ -25,9 +92,22 @@ type BinaryInfo struct {
 
 func LoadManifest(path string) (*Manifest, error) {
 	var m Manifest
-	if _, err := toml.DecodeFile(path, &m); err != nil {
+	unknown, deprecations, err := tomlfile.Decode(path, &m, deprecatedKeys...)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse manifest: %w", err)
 	}
+	if err := tomlfile.CheckSchemaVersion(m.SchemaVersion, SchemaVersion); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name, pkg := range m.Packages {
+		if err := tomlfile.CheckEnvNames(fmt.Sprintf("packages.%s.env", name), pkg.Env); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	m.Deprecations = deprecations
+	for _, key := range unknown {
+		m.Warnings = append(m.Warnings, key.In(path))
+	}
 	return &m, nil
 }
 
@@ -39,16 +119,74 @@ func (m *Manifest) GetPackage(name string) (*PackageDefinition, error) {
 	return &pkg, nil
 }
 
+// ExpandEnv resolves the {store} and {data} placeholders in a package's env
+func (p *PackageDefinition) ExpandEnv(storePath string, dataDir string) map[string]string {
+	if len(p.Env) == 0 {
+		return nil
+	}
+
+	replacer := strings.NewReplacer("{store}", storePath, "{data}", dataDir)
+	env := make(map[string]string, len(p.Env))
+	for key, value := range p.Env {
+		env[key] = replacer.Replace(value)
+	}
+	return env
+}
+
+// LibDir is the dir of a store entry libraries and certificates are kept in
+const LibDir = "lib"
+
+// LibraryFiles lists the files the package keeps in its store entry's lib dir
+func (p *PackageDefinition) LibraryFiles() []string {
+	files := slices.Clone(p.Libraries)
+	if p.Certificates != "" {
+		files = append(files, p.Certificates)
+	}
+	return files
+}
+
+// LibraryPaths is where a package installed at storePath keeps its
+// libraries and its CA bundle, each "" if it provides none
+func (p *PackageDefinition) LibraryPaths(storePath string) (libDir string, certFile string) {
+	if len(p.Libraries) > 0 {
+		libDir = filepath.Join(storePath, LibDir)
+	}
+	if p.Certificates != "" {
+		certFile = filepath.Join(storePath, LibDir, p.Certificates)
+	}
+	return libDir, certFile
+}
+
+// UsesDataDir reports whether the package's env points anywhere into its data dir
+func (p *PackageDefinition) UsesDataDir() bool {
+	for _, value := range p.Env {
+		if strings.Contains(value, "{data}") {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrReleaseAsset is returned by GetURL for a platform whose download is a
+// release asset, which only the forge's API can turn into a URL
+var ErrReleaseAsset = errors.New("download is a release asset")
+
 func (m *Manifest) GetURL(name, version string) (string, error) {
+	return m.GetURLFor(name, version, Platform())
+}
+
+// GetURLFor is GetURL for another platform, "<os>-<arch>" like the urls keys
+func (m *Manifest) GetURLFor(name, version, platform string) (string, error) {
 	pkg, err := m.GetPackage(name)
 	if err != nil {
 		return "", err
 	}
 
-	// Get platform-specific URL
-	platform := fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
 	urlTemplate, ok := pkg.URLs[platform]
 	if !ok {
+		if asset, ok := pkg.Asset(version, platform); ok {
+			return "", fmt.Errorf("%w %s of %s %s", ErrReleaseAsset, asset, pkg.Repo, pkg.ReleaseTag(version))
+		}
 		return "", fmt.Errorf("platform %s not supported for %s", platform, name)
 	}
 
@@ -56,3 +194,75 @@ func (m *Manifest) GetURL(name, version string) (string, error) {
 	url := strings.ReplaceAll(urlTemplate, "{version}", version)
 	return url, nil
 }
+
+// Asset is the name of the release asset to download for platform, if the
+// package names one
+func (p *PackageDefinition) Asset(version string, platform string) (string, bool) {
+	asset, ok := p.Assets[platform]
+	if !ok {
+		return "", false
+	}
+	return strings.ReplaceAll(asset, "{version}", version), true
+}
+
+// ReleaseTag is the tag of the release a version's assets are attached to
+func (p *PackageDefinition) ReleaseTag(version string) string {
+	tag := p.Tag
+	if tag == "" {
+		tag = "v{version}"
+	}
+	return strings.ReplaceAll(tag, "{version}", version)
+}
+
+// Platforms lists the platforms the package has a download for, sorted
+func (p *PackageDefinition) Platforms() []string {
+	platforms := make([]string, 0, len(p.URLs)+len(p.Assets))
+	for platform := range p.URLs {
+		platforms = append(platforms, platform)
+	}
+	for platform := range p.Assets {
+		if _, ok := p.URLs[platform]; !ok {
+			platforms = append(platforms, platform)
+		}
+	}
+	sort.Strings(platforms)
+	return platforms
+}
+
+// Platform is the urls key for the platform yourpm is running on
+func Platform() string {
+	return fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// TagVersion turns a release tag into the version its URLs expect. The tag
+// prefix is taken from the URL template where it reads
+// .../download/<prefix>{version}/... (GitHub, Gitea) or
+// .../releases/<prefix>{version}/... (GitLab), falling back to dropping a
+// leading "v". A package with assets takes it from Tag instead.
+func (p *PackageDefinition) TagVersion(tag string) string {
+	if len(p.Assets) > 0 {
+		prefix, suffix, _ := strings.Cut(p.ReleaseTag("{version}"), "{version}")
+		return strings.TrimSuffix(strings.TrimPrefix(tag, prefix), suffix)
+	}
+
+	// Platforms in order, so templates that disagree always resolve the same way
+	platforms := make([]string, 0, len(p.URLs))
+	for platform := range p.URLs {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	for _, marker := range []string{"/download/", "/releases/"} {
+		for _, platform := range platforms {
+			_, after, ok := strings.Cut(p.URLs[platform], marker)
+			if !ok {
+				continue
+			}
+			prefix, _, ok := strings.Cut(after, "{version}")
+			if ok && !strings.Contains(prefix, "/") {
+				return strings.TrimPrefix(tag, prefix)
+			}
+		}
+	}
+	return strings.TrimPrefix(tag, "v")
+}
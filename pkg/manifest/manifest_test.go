@@ -0,0 +1,82 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// FuzzLoadManifest checks that no manifest file makes loading it, or
+// resolving its packages' URLs, panic
+func FuzzLoadManifest(f *testing.F) {
+	f.Add([]byte(`
+[packages.ripgrep]
+repo = "BurntSushi/ripgrep"
+binaries.names = ["rg"]
+urls.linux-amd64 = "https://github.com/BurntSushi/ripgrep/releases/download/{version}/ripgrep-{version}-x86_64-unknown-linux-musl.tar.gz"
+`))
+	f.Add([]byte(`
+schema_version = 1
+
+[packages.go]
+env = { GOBIN = "{data}/bin", GOROOT = "{store}/go" }
+urls = { linux-amd64 = "https://go.dev/dl/go{version}.linux-amd64.tar.gz" }
+libraries = ["libgo.so"]
+certificates = "ca.pem"
+`))
+	f.Add([]byte(`schema_version = 99`))
+	f.Add([]byte(`[packages.x]
+urls = "not a table"`))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := filepath.Join(t.TempDir(), "manifest.toml")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := LoadManifest(path)
+		if err != nil {
+			return
+		}
+		for name, pkg := range m.Packages {
+			for platform := range pkg.URLs {
+				if _, err := m.GetURLFor(name, "1.2.3", platform); err != nil {
+					t.Errorf("%s has a URL for %s but GetURLFor failed: %v", name, platform, err)
+				}
+			}
+			pkg.TagVersion("v1.2.3")
+			pkg.ExpandEnv("/store", "/data")
+		}
+	})
+}
+
+// FuzzURLExpansion checks the {version} substitution and the tag prefix
+// taken from URL templates against arbitrary templates and tags
+func FuzzURLExpansion(f *testing.F) {
+	f.Add("https://github.com/cli/cli/releases/download/v{version}/gh_{version}_linux_amd64.tar.gz", "v2.40.0", "2.40.0")
+	f.Add("https://gitlab.com/o/r/-/releases/release-{version}/downloads/r.tar.gz", "release-1.0", "1.0")
+	f.Add("https://example.com/{version}{version}/{", "v", "")
+	f.Add("", "", "{version}")
+
+	f.Fuzz(func(t *testing.T, template string, tag string, version string) {
+		m := &Manifest{Packages: map[string]PackageDefinition{
+			"pkg": {URLs: map[string]string{"linux-amd64": template}},
+		}}
+		pkg, _ := m.GetPackage("pkg")
+
+		trimmed := pkg.TagVersion(tag)
+		if !strings.HasSuffix(tag, trimmed) {
+			t.Errorf("TagVersion(%q) = %q, which isn't what's left of the tag after a prefix", tag, trimmed)
+		}
+
+		url, err := m.GetURLFor("pkg", version, "linux-amd64")
+		if err != nil {
+			t.Fatalf("GetURLFor failed: %v", err)
+		}
+		if !strings.Contains(template, "{version}") && url != template {
+			t.Errorf("template %q has no {version} but expanded to %q", template, url)
+		}
+	})
+}
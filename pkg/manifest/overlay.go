@@ -0,0 +1,62 @@
+package manifest
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// LoadManifestWithOverlays loads the main manifest and merges every *.toml
+// drop-in from overlayDir over it. Drop-ins are read in lexical order and a
+// package they define replaces the main manifest's definition wholesale.
+// Two drop-ins defining the same package is an error, since neither one is
+// obviously meant to win. Without an overlayDir only path is loaded.
+func LoadManifestWithOverlays(path string, overlayDir string) (*Manifest, error) {
+	m, err := LoadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if m.Packages == nil {
+		m.Packages = make(map[string]PackageDefinition)
+	}
+
+	m.Sources = make(map[string]string, len(m.Packages))
+	for name := range m.Packages {
+		m.Sources[name] = path
+	}
+
+	if overlayDir == "" {
+		return m, nil
+	}
+	files, err := filepath.Glob(filepath.Join(overlayDir, "*.toml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	overlaid := make(map[string]string)
+	for _, file := range files {
+		overlay, err := LoadManifest(file)
+		if err != nil {
+			return nil, err
+		}
+		m.Warnings = append(m.Warnings, overlay.Warnings...)
+		m.Deprecations = append(m.Deprecations, overlay.Deprecations...)
+
+		for name, pkg := range overlay.Packages {
+			if previous, ok := overlaid[name]; ok {
+				return nil, fmt.Errorf("package %s is defined in both %s and %s", name, previous, file)
+			}
+			overlaid[name] = file
+			m.Packages[name] = pkg
+			m.Sources[name] = file
+		}
+	}
+
+	return m, nil
+}
+
+// Source is the manifest file a package definition came from
+func (m *Manifest) Source(name string) string {
+	return m.Sources[name]
+}
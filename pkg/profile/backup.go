@@ -0,0 +1,113 @@
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/crbroughton/pkg-exploration/pkg/guard"
+)
+
+// backupStamp names a backup set; it sorts in time order
+const backupStamp = "20060102-150405"
+
+func (p *Profile) backupRoot() string {
+	return filepath.Join(p.root, "backup")
+}
+
+// clear makes way for a command at target. Symlinks and files yourpm wrote
+// are removed; anything else is a file the user put there, so it is moved
+// into this run's backup set instead of being lost.
+func (p *Profile) clear(target string) error {
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := guard.Check(p.root, target); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 || generated(target) {
+		return os.Remove(target)
+	}
+
+	if p.backupDir == "" {
+		p.backupDir = filepath.Join(p.backupRoot(), time.Now().Format(backupStamp))
+	}
+	if err := os.MkdirAll(p.backupDir, 0755); err != nil {
+		return err
+	}
+	backup := filepath.Join(p.backupDir, filepath.Base(target))
+	if err := os.Rename(target, backup); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", target, err)
+	}
+	p.backups = append(p.backups, backup)
+	return nil
+}
+
+// generated reports whether path is a shim or wrapper yourpm wrote
+func generated(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, len(shimHeader))
+	n, _ := f.Read(header)
+	return bytes.Equal(header[:n], []byte(shimHeader))
+}
+
+// Backups returns the user files moved aside by this profile so far
+func (p *Profile) Backups() []string {
+	return p.backups
+}
+
+// BackupSets lists the backup sets, oldest first
+func (p *Profile) BackupSets() ([]string, error) {
+	entries, err := os.ReadDir(p.backupRoot())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			sets = append(sets, entry.Name())
+		}
+	}
+	sort.Strings(sets)
+	return sets, nil
+}
+
+// RestoreBackups moves the files of a backup set back into the bin dir,
+// replacing whatever yourpm linked there, and removes the set. It returns
+// the commands restored.
+func (p *Profile) RestoreBackups(set string) ([]string, error) {
+	dir := filepath.Join(p.backupRoot(), set)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var restored []string
+	for _, entry := range entries {
+		target := filepath.Join(p.BinDir(), entry.Name())
+		if err := guard.Remove(p.root, target); err != nil && !os.IsNotExist(err) {
+			return restored, err
+		}
+		if err := os.Rename(filepath.Join(dir, entry.Name()), target); err != nil {
+			return restored, err
+		}
+		restored = append(restored, entry.Name())
+	}
+	return restored, os.Remove(dir)
+}
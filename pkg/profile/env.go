@@ -0,0 +1,179 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Activation is the environment a profile exports on top of its bin directory
+type Activation struct {
+	Env         map[string]string
+	PathPrepend []string
+	PathAppend  []string
+}
+
+func (p *Profile) BinDir() string {
+	return filepath.Join(p.root, "bin")
+}
+
+func (p *Profile) ActivationPath() string {
+	return filepath.Join(p.root, "activate.sh")
+}
+
+// Path builds the PATH value with the profile bin dir ahead of the current PATH
+func (p *Profile) Path(a Activation, current string) string {
+	var parts []string
+	for _, dir := range a.PathPrepend {
+		parts = append(parts, os.ExpandEnv(dir))
+	}
+	parts = append(parts, p.BinDir())
+	if current != "" {
+		parts = append(parts, current)
+	}
+	for _, dir := range a.PathAppend {
+		parts = append(parts, os.ExpandEnv(dir))
+	}
+	return strings.Join(parts, string(os.PathListSeparator))
+}
+
+// Environ returns base with PATH and the activation variables applied
+func (p *Profile) Environ(a Activation, base []string) []string {
+	overrides := map[string]string{
+		"PATH": p.Path(a, os.Getenv("PATH")),
+	}
+	for key, value := range a.Env {
+		overrides[key] = os.ExpandEnv(value)
+	}
+
+	var env []string
+	for _, kv := range base {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, ok := overrides[key]; ok {
+			continue
+		}
+		env = append(env, kv)
+	}
+	for _, key := range sortedKeys(overrides) {
+		env = append(env, key+"="+overrides[key])
+	}
+	return env
+}
+
+// ActivationScript renders a POSIX shell script exporting the profile environment.
+// Values are double quoted so $VAR references are expanded by the shell.
+// Before a variable is first set in a shell its old value is saved and its
+// name added to _YOURPM_VARS, so DeactivationScript can put it back.
+func (p *Profile) ActivationScript(a Activation) string {
+	var b strings.Builder
+	b.WriteString("# Generated by yourpm, do not edit\n")
+
+	var path []string
+	for _, dir := range a.PathPrepend {
+		path = append(path, shellQuote(dir))
+	}
+	path = append(path, shellQuote(p.BinDir()), "$PATH")
+	for _, dir := range a.PathAppend {
+		path = append(path, shellQuote(dir))
+	}
+	fmt.Fprintf(&b, "export PATH=\"%s\"\n", strings.Join(path, ":"))
+
+	for _, key := range sortedKeys(a.Env) {
+		fmt.Fprintf(&b, "case \" ${%s:-} \" in *\" %s \"*) ;; *) if [ -n \"${%s+x}\" ]; then export %s%s=\"$%s\"; fi; export %s=\"${%s:+$%s }%s\" ;; esac\n",
+			varsKey, key, key, savedPrefix, key, key, varsKey, varsKey, varsKey, key)
+		fmt.Fprintf(&b, "export %s=\"%s\"\n", key, shellQuote(a.Env[key]))
+	}
+	return b.String()
+}
+
+// varsKey lists the variables activation scripts set in this shell, and
+// savedPrefix names where each one's earlier value is kept
+const (
+	varsKey     = "_YOURPM_VARS"
+	savedPrefix = "_YOURPM_OLD_"
+)
+
+// DeactivationScript renders a POSIX shell script undoing ActivationScript
+// in a shell whose PATH is currentPath and whose _YOURPM_VARS is vars: it
+// drops the entries activation added to PATH, restores the variables it
+// saved and unsets those that weren't set before
+func (p *Profile) DeactivationScript(a Activation, currentPath string, vars string) string {
+	added := map[string]bool{p.BinDir(): true}
+	for _, dir := range append(slices.Clone(a.PathPrepend), a.PathAppend...) {
+		added[os.ExpandEnv(dir)] = true
+	}
+	var kept []string
+	for _, dir := range filepath.SplitList(currentPath) {
+		if !added[dir] {
+			kept = append(kept, dir)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "export PATH='%s'\n", strings.ReplaceAll(strings.Join(kept, string(os.PathListSeparator)), "'", `'\''`))
+	for _, key := range strings.Fields(vars) {
+		fmt.Fprintf(&b, "if [ -n \"${%s%s+x}\" ]; then export %s=\"$%s%s\"; unset %s%s; else unset %s; fi\n",
+			savedPrefix, key, key, savedPrefix, key, savedPrefix, key, key)
+	}
+	fmt.Fprintf(&b, "unset %s\n", varsKey)
+	return b.String()
+}
+
+// EnvironmentD renders the environment in systemd environment.d format,
+// which expands $VAR references against what was set before it. Values
+// are double quoted, and one with a newline is refused since the format
+// has no way to write it.
+func (p *Profile) EnvironmentD(a Activation) (string, error) {
+	var b strings.Builder
+	b.WriteString("# Generated by yourpm, do not edit\n")
+
+	var path []string
+	path = append(path, a.PathPrepend...)
+	path = append(path, p.BinDir(), "${PATH}")
+	path = append(path, a.PathAppend...)
+	if err := writeEnvironmentD(&b, "PATH", strings.Join(path, ":")); err != nil {
+		return "", err
+	}
+
+	for _, key := range sortedKeys(a.Env) {
+		if err := writeEnvironmentD(&b, key, a.Env[key]); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// writeEnvironmentD writes one environment.d assignment, whose double
+// quotes take the same escapes as the shell's
+func writeEnvironmentD(b *strings.Builder, key string, value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("%s contains a newline, which environment.d can't hold", key)
+	}
+	fmt.Fprintf(b, "%s=\"%s\"\n", key, shellQuote(value))
+	return nil
+}
+
+func (p *Profile) WriteActivation(a Activation) error {
+	if err := os.MkdirAll(p.root, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p.ActivationPath(), []byte(p.ActivationScript(a)), 0644)
+}
+
+// shellQuote escapes a value for use inside double quotes, leaving $ expansion intact
+func shellQuote(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`")
+	return replacer.Replace(value)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
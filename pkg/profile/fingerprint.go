@@ -0,0 +1,22 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FingerprintPath is where the profile records which config it was switched
+// to, for shims to tell when it has changed since
+func (p *Profile) FingerprintPath() string {
+	return filepath.Join(p.root, "fingerprint")
+}
+
+// WriteFingerprint records the config's path and sha256, a line each, so a
+// shell script can read them without parsing TOML
+func (p *Profile) WriteFingerprint(configPath string, hash string) error {
+	if err := os.MkdirAll(p.root, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p.FingerprintPath(), []byte(fmt.Sprintf("%s\n%s\n", configPath, hash)), 0644)
+}
@@ -0,0 +1,108 @@
+package profile
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testProfile is a profile in a temp dir, with the dir it lives in
+func testProfile(t *testing.T) (*Profile, string) {
+	t.Helper()
+	root := t.TempDir()
+	return NewProfile(filepath.Join(root, "profile")), root
+}
+
+// checkGolden compares got with testdata/name, after replacing root with
+// /yourpm so the files don't depend on where the test ran
+func checkGolden(t *testing.T, name string, root string, got string) {
+	t.Helper()
+	got = strings.ReplaceAll(got, root, "/yourpm")
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run go test -update to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the golden file, run go test -update if the change is intended\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+// readBin reads what the profile has in its bin dir for binary
+func readBin(t *testing.T, p *Profile, binary string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(p.BinDir(), binary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestShimGolden(t *testing.T) {
+	p, root := testProfile(t)
+	storeRoot := filepath.Join(root, "store")
+	if err := p.Shim("ripgrep", storeRoot, filepath.Join(storeRoot, "ripgrep-14.1.0"), []string{"rg"}); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "shim.golden", root, readBin(t, p, "rg"))
+}
+
+func TestWrapGolden(t *testing.T) {
+	p, root := testProfile(t)
+	storePath := filepath.Join(root, "store", "ripgrep-14.1.0")
+	if err := p.Link(storePath, []string{"rg"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Wrap(storePath, "rg", []string{"nice -n10", "env RG_CONFIG=$HOME/.rgrc"}); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "wrap.golden", root, readBin(t, p, "rg"))
+}
+
+func TestSandboxGolden(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the bwrap wrapper is written on Linux only")
+	}
+	// Sandbox only checks that bwrap is on PATH
+	tools := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tools, "bwrap"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", tools)
+
+	p, root := testProfile(t)
+	spec := SandboxSpec{Write: []string{"{cwd}", "{home}/.cache/rg"}}
+	if err := p.Sandbox(filepath.Join(root, "store", "ripgrep-14.1.0"), []string{"rg"}, spec); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "sandbox-bwrap.golden", root, readBin(t, p, "rg"))
+}
+
+func TestSandboxExecGolden(t *testing.T) {
+	spec := SandboxSpec{Write: []string{"{cwd}", "{home}/.cache/rg"}, Network: true}
+	checkGolden(t, "sandbox-exec.golden", "/yourpm", sandboxExecWrapper("/yourpm/store/ripgrep-14.1.0/rg", spec))
+}
+
+func TestActivationScriptGolden(t *testing.T) {
+	p, root := testProfile(t)
+	a := Activation{
+		Env: map[string]string{
+			"GOPATH":   "$HOME/go",
+			"GREETING": `say "hi" with a \ and a ` + "`backtick`",
+		},
+		PathPrepend: []string{"$HOME/go/bin"},
+		PathAppend:  []string{"/opt/tools bin"},
+	}
+	checkGolden(t, "activate.golden", root, p.ActivationScript(a))
+}
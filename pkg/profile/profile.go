@@ -8,6 +8,10 @@ import (
 
 type Profile struct {
 	root string
+
+	// backupDir is this run's backup set, created on first use
+	backupDir string
+	backups   []string
 }
 
 func NewProfile(root string) *Profile {
@@ -27,8 +31,9 @@ func (p *Profile) Link(storePath string, binaries []string) error {
 		source := filepath.Join(storePath, binary)
 		target := filepath.Join(binDir, binary)
 
-		// Remove existing symlink
-		os.Remove(target)
+		if err := p.clear(target); err != nil {
+			return err
+		}
 
 		// Create symlink
 		if err := os.Symlink(source, target); err != nil {
@@ -0,0 +1,102 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// SandboxSpec is what a sandboxed binary may touch. Everything is readable,
+// only Write paths (and a private /tmp on Linux) are writable, and the
+// network is cut off unless Network is set. Paths may use {cwd} and {home}.
+type SandboxSpec struct {
+	Write   []string
+	Network bool
+}
+
+// Sandbox writes a wrapper per binary that runs it under bubblewrap on Linux
+// or sandbox-exec on macOS, instead of linking it directly
+func (p *Profile) Sandbox(storePath string, binaries []string, spec SandboxSpec) error {
+	binDir := p.BinDir()
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return err
+	}
+
+	wrapper := bwrapWrapper
+	tool := "bwrap"
+	switch runtime.GOOS {
+	case "linux":
+	case "darwin":
+		wrapper = sandboxExecWrapper
+		tool = "sandbox-exec"
+	default:
+		return fmt.Errorf("sandboxing is not supported on %s", runtime.GOOS)
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return fmt.Errorf("sandboxing needs %s installed: %w", tool, err)
+	}
+
+	for _, binary := range binaries {
+		source := filepath.Join(storePath, binary)
+		target := filepath.Join(binDir, binary)
+		script := wrapper(source, spec)
+
+		if err := p.clear(target); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(target, []byte(script), 0755); err != nil {
+			return fmt.Errorf("failed to write sandbox wrapper for %s: %w", binary, err)
+		}
+	}
+
+	return nil
+}
+
+// sandboxPath turns a spec path into a double quoted shell word
+func sandboxPath(path string) string {
+	path = shellQuote(path)
+	path = strings.ReplaceAll(path, "{cwd}", "$PWD")
+	path = strings.ReplaceAll(path, "{home}", "$HOME")
+	return `"` + path + `"`
+}
+
+func bwrapWrapper(source string, spec SandboxSpec) string {
+	var b strings.Builder
+	b.WriteString(shimHeader)
+	b.WriteString("exec bwrap --ro-bind / / --dev /dev --proc /proc --tmpfs /tmp \\\n")
+	for _, path := range spec.Write {
+		fmt.Fprintf(&b, "\t--bind %s %s \\\n", sandboxPath(path), sandboxPath(path))
+	}
+	if !spec.Network {
+		b.WriteString("\t--unshare-net \\\n")
+	}
+	fmt.Fprintf(&b, "\t--die-with-parent --chdir \"$PWD\" -- \"%s\" \"$@\"\n", shellQuote(source))
+	return b.String()
+}
+
+func sandboxExecWrapper(source string, spec SandboxSpec) string {
+	var rules strings.Builder
+	rules.WriteString("(version 1)(allow default)(deny file-write*)")
+	rules.WriteString(`(allow file-write* (subpath "/dev") (subpath (param "TMPDIR"))`)
+	for i := range spec.Write {
+		fmt.Fprintf(&rules, ` (subpath (param "WRITE%d"))`, i)
+	}
+	rules.WriteString(")")
+	if !spec.Network {
+		rules.WriteString("(deny network*)")
+	}
+
+	var b strings.Builder
+	b.WriteString(shimHeader)
+	fmt.Fprintf(&b, "exec sandbox-exec -p '%s' \\\n", rules.String())
+	b.WriteString("\t-D TMPDIR=\"${TMPDIR:-/tmp}\" \\\n")
+	for i, path := range spec.Write {
+		fmt.Fprintf(&b, "\t-D WRITE%d=%s \\\n", i, sandboxPath(path))
+	}
+	fmt.Fprintf(&b, "\t\"%s\" \"$@\"\n", shellQuote(source))
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Shadowed finds binaries that would resolve to something outside the profile
+// once activated: anything in a path_prepend dir, or in a dir ahead of the
+// profile bin dir when the current PATH already includes it.
+// The result maps each shadowed binary to the path that wins.
+func (p *Profile) Shadowed(a Activation, current string, binaries []string) map[string]string {
+	var ahead []string
+	for _, dir := range a.PathPrepend {
+		ahead = append(ahead, os.ExpandEnv(dir))
+	}
+	dirs := filepath.SplitList(current)
+	for i, dir := range dirs {
+		if filepath.Clean(dir) == p.BinDir() {
+			ahead = append(ahead, dirs[:i]...)
+			break
+		}
+	}
+
+	shadowed := make(map[string]string)
+	for _, binary := range binaries {
+		for _, dir := range ahead {
+			candidate := filepath.Join(dir, binary)
+			info, err := os.Stat(candidate)
+			if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+				continue
+			}
+			shadowed[binary] = candidate
+			break
+		}
+	}
+	return shadowed
+}
@@ -0,0 +1,82 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const shimHeader = "#!/bin/sh\n# Generated by yourpm, do not edit\n"
+
+// shimTemplate looks for a version pin in .yourpm-version or .tool-versions
+// (asdf format, "<package> <version>" per line) in the current directory and
+// its parents, falling back to the version the profile was switched to.
+// First it warns if the config changed since the switch, hashing it only
+// when it is newer than the profile's fingerprint.
+const shimTemplate = shimHeader + `fingerprint="%[5]s"
+if [ -f "$fingerprint" ]; then
+	{ read -r config; read -r sum; } < "$fingerprint"
+	if [ "$config" -nt "$fingerprint" ]; then
+		now=$({ sha256sum "$config" || shasum -a 256 "$config"; } 2>/dev/null | cut -d' ' -f1)
+		if [ -n "$now" ] && [ "$now" != "$sum" ]; then
+			echo "yourpm: environment is stale, $config changed since the last switch, run yourpm switch" >&2
+		fi
+	fi
+fi
+
+pkg="%[1]s"
+version=""
+dir="$PWD"
+while [ -z "$version" ]; do
+	for file in "$dir/.yourpm-version" "$dir/.tool-versions"; do
+		if [ -f "$file" ]; then
+			version=$(awk -v pkg="$pkg" '$1 == pkg { print $2; exit }' "$file")
+			[ -n "$version" ] && break
+		fi
+	done
+	[ "$dir" = "/" ] && break
+	dir=$(dirname "$dir")
+done
+
+if [ -z "$version" ]; then
+	if [ ! -x "%[3]s" ]; then
+		# The store entry went missing, reinstall it from the recorded download
+		yourpm repair "$pkg" >&2 || exit 127
+	fi
+	exec "%[3]s" "$@"
+fi
+
+target="%[2]s/$pkg-$version/%[4]s"
+if [ ! -x "$target" ]; then
+	echo "yourpm: $pkg $version is not installed (pinned in $file)" >&2
+	echo "yourpm: add it to your config and run yourpm switch" >&2
+	exit 127
+fi
+exec "$target" "$@"
+`
+
+// Shim writes a version-selecting shim for each binary instead of a symlink.
+// storeRoot is where the shim looks for other versions of the package.
+func (p *Profile) Shim(name string, storeRoot string, storePath string, binaries []string) error {
+	binDir := p.BinDir()
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return err
+	}
+
+	for _, binary := range binaries {
+		source := filepath.Join(storePath, binary)
+		target := filepath.Join(binDir, binary)
+
+		script := fmt.Sprintf(shimTemplate, shellQuote(name), shellQuote(storeRoot), shellQuote(source), shellQuote(binary), shellQuote(p.FingerprintPath()))
+
+		if err := p.clear(target); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(target, []byte(script), 0755); err != nil {
+			return fmt.Errorf("failed to write shim for %s: %w", binary, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package profile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/guard"
+)
+
+// Unlink removes the commands a package exposed, leaving anything that
+// yourpm didn't create (or that now belongs to another package) alone.
+// It returns the binaries it actually removed.
+func (p *Profile) Unlink(storePath string, binaries []string) ([]string, error) {
+	var removed []string
+	for _, binary := range binaries {
+		target := filepath.Join(p.BinDir(), binary)
+		if !p.owns(target, storePath) {
+			continue
+		}
+		if err := guard.Remove(p.root, target); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		guard.Remove(p.root, filepath.Join(p.wrappedDir(), binary))
+		removed = append(removed, binary)
+	}
+	return removed, nil
+}
+
+// owns reports whether target is a symlink into storePath, or a shim or
+// sandbox wrapper that runs a binary from it
+func (p *Profile) owns(target string, storePath string) bool {
+	if dest, err := os.Readlink(target); err == nil {
+		return strings.HasPrefix(dest, storePath+string(filepath.Separator))
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil || !bytes.HasPrefix(data, []byte(shimHeader)) {
+		return false
+	}
+	return bytes.Contains(data, []byte(`"`+shellQuote(storePath)+string(filepath.Separator)))
+}
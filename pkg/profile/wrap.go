@@ -0,0 +1,46 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/guard"
+)
+
+// wrapTemplate runs the wrapped command under the configured layers. The
+// comment names the store binary so owns and Unlink recognise the wrapper.
+const wrapTemplate = shimHeader + `# wraps "%s"
+exec %s "%s" "$@"
+`
+
+func (p *Profile) wrappedDir() string {
+	return filepath.Join(p.root, "wrapped")
+}
+
+// Wrap puts layers in front of a command already linked, shimmed or
+// sandboxed into the bin dir: that entry moves to wrapped/ and a script
+// running it through the layers takes its place. Layers are command
+// prefixes like "nice -n10", outermost first, written into the script
+// as is so they may use $VARS.
+func (p *Profile) Wrap(storePath string, binary string, layers []string) error {
+	if err := os.MkdirAll(p.wrappedDir(), 0755); err != nil {
+		return err
+	}
+
+	target := filepath.Join(p.BinDir(), binary)
+	inner := filepath.Join(p.wrappedDir(), binary)
+	if err := guard.Remove(p.root, inner); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(target, inner); err != nil {
+		return fmt.Errorf("failed to wrap %s: %w", binary, err)
+	}
+
+	script := fmt.Sprintf(wrapTemplate, shellQuote(filepath.Join(storePath, binary)), strings.Join(layers, " "), shellQuote(inner))
+	if err := os.WriteFile(target, []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write wrapper for %s: %w", binary, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNotInChecksumDB is returned when the database has no entry for an artifact
+var ErrNotInChecksumDB = errors.New("not in the checksum database")
+
+// LookupChecksum asks a checksum database for the sha256 of a package
+// artifact. The database answers GET <db>/<name>/<version>/<platform> with
+// the hex digest, optionally followed by other fields, and 404 when it has
+// no entry.
+func (r *HttpRepository) LookupChecksum(ctx context.Context, db string, name string, version string, platform string) (string, error) {
+	endpoint := fmt.Sprintf("%s/%s/%s/%s", strings.TrimRight(db, "/"), url.PathEscape(name), url.PathEscape(version), platform)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	r.authorize(req)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", ErrNotInChecksumDB
+	default:
+		return "", fmt.Errorf("checksum database returned HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum database returned an empty answer for %s@%s", name, version)
+	}
+	return strings.ToLower(fields[0]), nil
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const githubAPI = "https://api.github.com"
+
+// LatestRelease returns the tag of a GitHub repo's latest release. repo is
+// "owner/name". GITHUB_TOKEN, or else a token saved for github.com, is used
+// to avoid the anonymous rate limit. Responses are cached and revalidated
+// with their ETag, which GitHub doesn't count against the limit, and while
+// the anonymous limit is exhausted the cached tag is returned without
+// asking. A token has its own limit, so the saved reset doesn't apply to it.
+func (r *HttpRepository) LatestRelease(ctx context.Context, repo string) (string, error) {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" && r.credentials != nil {
+		token = r.credentials("github.com")
+	}
+
+	cached := r.loadRelease(repo)
+	if token == "" {
+		if reset := r.rateLimitedUntil(); !reset.IsZero() {
+			if cached != nil {
+				return cached.Tag, nil
+			}
+			return "", fmt.Errorf("GitHub rate limit exceeded until %s, log in with yourpm auth login github", reset.Format(time.Kitchen))
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/repos/%s/releases/latest", githubAPI, repo), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if token == "" {
+		r.recordRateLimit(resp)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return cached.Tag, nil
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		if cached != nil {
+			return cached.Tag, nil
+		}
+		return "", fmt.Errorf("GitHub refused the request for %s (HTTP %d), likely rate limited; log in with yourpm auth login github", repo, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("GitHub returned HTTP %d for %s", resp.StatusCode, repo)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("failed to parse release of %s: %w", repo, err)
+	}
+	r.saveRelease(repo, cachedRelease{ETag: resp.Header.Get("ETag"), Tag: release.TagName, Fetched: time.Now()})
+	return release.TagName, nil
+}
+
+// DeviceCode is what the user needs to approve a device flow login
+type DeviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// GitHubDeviceLogin runs GitHub's OAuth device flow for the OAuth app
+// clientID. It calls prompt with the code the user must enter, then polls
+// until they approve it and returns the access token.
+func (r *HttpRepository) GitHubDeviceLogin(ctx context.Context, clientID string, scope string, prompt func(code DeviceCode)) (string, error) {
+	var code DeviceCode
+	if err := r.postForm(ctx, "https://github.com/login/device/code", url.Values{
+		"client_id": {clientID},
+		"scope":     {scope},
+	}, &code); err != nil {
+		return "", fmt.Errorf("failed to start device login: %w", err)
+	}
+	prompt(code)
+
+	interval := time.Duration(code.Interval) * time.Second
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(interval):
+		}
+
+		var result struct {
+			AccessToken string `json:"access_token"`
+			Error       string `json:"error"`
+			Interval    int    `json:"interval"`
+		}
+		if err := r.postForm(ctx, "https://github.com/login/oauth/access_token", url.Values{
+			"client_id":   {clientID},
+			"device_code": {code.DeviceCode},
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		}, &result); err != nil {
+			return "", err
+		}
+
+		switch result.Error {
+		case "":
+			return result.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval = time.Duration(result.Interval) * time.Second
+		default:
+			return "", fmt.Errorf("device login failed: %s", result.Error)
+		}
+	}
+	return "", fmt.Errorf("device login expired before it was approved")
+}
+
+func (r *HttpRepository) postForm(ctx context.Context, endpoint string, form url.Values, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub returned HTTP %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cachedRelease is a GitHub API response kept to revalidate with its ETag,
+// and to fall back on while rate limited
+type cachedRelease struct {
+	ETag    string    `json:"etag"`
+	Tag     string    `json:"tag"`
+	Fetched time.Time `json:"fetched"`
+}
+
+func (r *HttpRepository) githubCacheDir() string {
+	return filepath.Join(r.cacheDir, "github")
+}
+
+func (r *HttpRepository) releaseCachePath(repo string) string {
+	return filepath.Join(r.githubCacheDir(), strings.ReplaceAll(repo, "/", "_")+".json")
+}
+
+func (r *HttpRepository) loadRelease(repo string) *cachedRelease {
+	data, err := os.ReadFile(r.releaseCachePath(repo))
+	if err != nil {
+		return nil
+	}
+	var cached cachedRelease
+	if json.Unmarshal(data, &cached) != nil {
+		return nil
+	}
+	return &cached
+}
+
+// saveRelease is best effort, a failed write only costs a request next time
+func (r *HttpRepository) saveRelease(repo string, cached cachedRelease) {
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(r.githubCacheDir(), 0755) != nil {
+		return
+	}
+	os.WriteFile(r.releaseCachePath(repo), data, 0644)
+}
+
+func (r *HttpRepository) rateLimitPath() string {
+	return filepath.Join(r.githubCacheDir(), "ratelimit")
+}
+
+// rateLimitedUntil is when GitHub said the exhausted anonymous rate limit
+// resets, or the zero time when requests are allowed
+func (r *HttpRepository) rateLimitedUntil() time.Time {
+	data, err := os.ReadFile(r.rateLimitPath())
+	if err != nil {
+		return time.Time{}
+	}
+	reset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || time.Now().Unix() >= reset {
+		return time.Time{}
+	}
+	return time.Unix(reset, 0)
+}
+
+// recordRateLimit remembers the reset time once the limit is used up, so
+// later calls don't spend requests that will only be refused
+func (r *HttpRepository) recordRateLimit(resp *http.Response) {
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		os.Remove(r.rateLimitPath())
+		return
+	}
+	if os.MkdirAll(r.githubCacheDir(), 0755) != nil {
+		return
+	}
+	os.WriteFile(r.rateLimitPath(), []byte(resp.Header.Get("X-RateLimit-Reset")), 0644)
+}
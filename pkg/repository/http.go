@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,6 +14,9 @@ import (
 type HttpRepository struct {
 	client   *http.Client
 	cacheDir string
+
+	// credentials returns the token for a host, or "" for none
+	credentials func(host string) string
 }
 
 func (r *HttpRepository) Name() string {
@@ -25,7 +30,55 @@ func NewHttpRepository(cacheDir string) *HttpRepository {
 	}
 }
 
+// Client is the client downloads go through, for other requests that need
+// the same trusted certificates
+func (r *HttpRepository) Client() *http.Client {
+	return r.client
+}
+
+// UseCredentials sends lookup's token for a request's host as a bearer token
+func (r *HttpRepository) UseCredentials(lookup func(host string) string) {
+	r.credentials = lookup
+}
+
+// authorize adds the host's token to req, if there is one. Go's client drops
+// the header when a redirect leaves the host, so it doesn't leak to CDNs.
+func (r *HttpRepository) authorize(req *http.Request) {
+	if r.credentials == nil || req.Header.Get("Authorization") != "" {
+		return
+	}
+	if token := r.credentials(req.URL.Hostname()); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// ContentLength asks the server how big a download is, returning -1 when it won't say
+func (r *HttpRepository) ContentLength(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return -1, err
+	}
+	r.authorize(req)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return -1, fmt.Errorf("HEAD failed: HTTP %d", resp.StatusCode)
+	}
+	return resp.ContentLength, nil
+}
+
 func (r *HttpRepository) DownloadFile(ctx context.Context, url string, dest string) error {
+	return r.DownloadFileWithProgress(ctx, url, dest, nil)
+}
+
+// DownloadFileWithProgress is DownloadFile calling progress as bytes arrive,
+// with total -1 when the server doesn't send a length
+func (r *HttpRepository) DownloadFileWithProgress(ctx context.Context, url string, dest string, progress func(done int64, total int64)) error {
 	if _, err := os.Stat(dest); err == nil {
 		return nil
 	}
@@ -38,6 +91,7 @@ func (r *HttpRepository) DownloadFile(ctx context.Context, url string, dest stri
 	if err != nil {
 		return err
 	}
+	r.authorize(req)
 
 	resp, err := r.client.Do(req)
 	if err != nil {
@@ -46,7 +100,7 @@ func (r *HttpRepository) DownloadFile(ctx context.Context, url string, dest stri
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
+		return fmt.Errorf("server returned HTTP %d", resp.StatusCode)
 	}
 
 	tempFile := dest + ".tmp"
@@ -56,10 +110,46 @@ func (r *HttpRepository) DownloadFile(ctx context.Context, url string, dest stri
 	}
 	defer out.Close()
 
-	if _, err := io.Copy(out, resp.Body); err != nil {
+	var body io.Reader = resp.Body
+	if progress != nil {
+		body = &progressReader{reader: resp.Body, total: resp.ContentLength, progress: progress}
+	}
+
+	if _, err := io.Copy(out, body); err != nil {
 		os.Remove(tempFile)
 		return err
 	}
 
 	return os.Rename(tempFile, dest)
 }
+
+type progressReader struct {
+	reader   io.Reader
+	done     int64
+	total    int64
+	progress func(done int64, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	if n > 0 {
+		p.done += int64(n)
+		p.progress(p.done, p.total)
+	}
+	return n, err
+}
+
+// Digest returns the hex encoded sha256 of a downloaded file
+func Digest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Probe is what a HEAD request found at a download URL
+type Probe struct {
+	Status int
+
+	// MovedTo is where a permanent redirect (301 or 308) along the way
+	// pointed, which usually means upstream renamed the repo or asset.
+	// Temporary redirects, like GitHub's to its CDN, are followed silently.
+	MovedTo string
+}
+
+// maxRedirects matches the limit Go's client applies when following redirects itself
+const maxRedirects = 10
+
+// ProbeURL HEADs url, following redirects by hand so permanent ones are reported
+func (r *HttpRepository) ProbeURL(ctx context.Context, rawURL string) (Probe, error) {
+	client := *r.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	var probe Probe
+	for range maxRedirects {
+		req, err := http.NewRequestWithContext(ctx, "HEAD", rawURL, nil)
+		if err != nil {
+			return probe, err
+		}
+		r.authorize(req)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return probe, err
+		}
+		resp.Body.Close()
+
+		probe.Status = resp.StatusCode
+		location := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+			return probe, nil
+		}
+
+		next, err := req.URL.Parse(location)
+		if err != nil {
+			return probe, fmt.Errorf("bad redirect to %q: %w", location, err)
+		}
+		if probe.MovedTo == "" && (resp.StatusCode == http.StatusMovedPermanently || resp.StatusCode == http.StatusPermanentRedirect) {
+			probe.MovedTo = next.String()
+		}
+		// authorize looks the token up again for the next host, so it
+		// doesn't follow the redirect off to a CDN
+		rawURL = next.String()
+	}
+	return probe, fmt.Errorf("stopped after %d redirects", maxRedirects)
+}
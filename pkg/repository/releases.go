@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Release sources a package's repo can live on
+const (
+	SourceGitHub = "github"
+	SourceGitLab = "gitlab"
+	SourceGitea  = "gitea"
+)
+
+// LatestReleaseFrom returns the tag of repo's latest release on source.
+// host is only used by gitlab and gitea, and defaults to their public
+// instances. A token saved for the host is sent along.
+func (r *HttpRepository) LatestReleaseFrom(ctx context.Context, source string, host string, repo string) (string, error) {
+	switch source {
+	case "", SourceGitHub:
+		return r.LatestRelease(ctx, repo)
+	case SourceGitLab:
+		host = forgeHost(source, host)
+		endpoint := fmt.Sprintf("https://%s/api/v4/projects/%s/releases/permalink/latest", host, url.PathEscape(repo))
+		var release struct {
+			TagName string `json:"tag_name"`
+		}
+		err := r.forgeGet(ctx, endpoint, host, "Bearer ", repo, &release)
+		return release.TagName, err
+	case SourceGitea:
+		host = forgeHost(source, host)
+		endpoint := fmt.Sprintf("https://%s/api/v1/repos/%s/releases/latest", host, repo)
+		var release struct {
+			TagName string `json:"tag_name"`
+		}
+		err := r.forgeGet(ctx, endpoint, host, "token ", repo, &release)
+		return release.TagName, err
+	default:
+		return "", fmt.Errorf("unknown release source %q, use %q, %q or %q", source, SourceGitHub, SourceGitLab, SourceGitea)
+	}
+}
+
+// ReleaseAsset returns the download URL of the asset called name in repo's
+// release tag on source, from the forge's release API. Only gitlab and
+// gitea are supported; the host's token is sent along, so assets of
+// private projects resolve too.
+func (r *HttpRepository) ReleaseAsset(ctx context.Context, source string, host string, repo string, tag string, name string) (string, error) {
+	host = forgeHost(source, host)
+	switch source {
+	case SourceGitLab:
+		endpoint := fmt.Sprintf("https://%s/api/v4/projects/%s/releases/%s", host, url.PathEscape(repo), url.PathEscape(tag))
+		var release struct {
+			Assets struct {
+				Links []struct {
+					Name           string `json:"name"`
+					URL            string `json:"url"`
+					DirectAssetURL string `json:"direct_asset_url"`
+				} `json:"links"`
+			} `json:"assets"`
+		}
+		if err := r.forgeGet(ctx, endpoint, host, "Bearer ", repo, &release); err != nil {
+			return "", err
+		}
+		for _, link := range release.Assets.Links {
+			if link.Name != name {
+				continue
+			}
+			if link.DirectAssetURL != "" {
+				return link.DirectAssetURL, nil
+			}
+			return link.URL, nil
+		}
+	case SourceGitea:
+		endpoint := fmt.Sprintf("https://%s/api/v1/repos/%s/releases/tags/%s", host, repo, url.PathEscape(tag))
+		var release struct {
+			Assets []struct {
+				Name               string `json:"name"`
+				BrowserDownloadURL string `json:"browser_download_url"`
+			} `json:"assets"`
+		}
+		if err := r.forgeGet(ctx, endpoint, host, "token ", repo, &release); err != nil {
+			return "", err
+		}
+		for _, asset := range release.Assets {
+			if asset.Name == name {
+				return asset.BrowserDownloadURL, nil
+			}
+		}
+	default:
+		return "", fmt.Errorf("release assets need source %q or %q, not %q", SourceGitLab, SourceGitea, source)
+	}
+	return "", fmt.Errorf("release %s of %s has no asset %s", tag, repo, name)
+}
+
+// forgeHost is host, or the public instance of source without one
+func forgeHost(source string, host string) string {
+	switch {
+	case host != "":
+		return host
+	case source == SourceGitLab:
+		return "gitlab.com"
+	default:
+		return "codeberg.org"
+	}
+}
+
+// forgeGet decodes a GitLab or Gitea API response into v, sending the token
+// saved for host with the auth scheme the forge expects
+func (r *HttpRepository) forgeGet(ctx context.Context, endpoint string, host string, scheme string, repo string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if r.credentials != nil {
+		if token := r.credentials(host); token != "" {
+			req.Header.Set("Authorization", scheme+token)
+		}
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP %d for %s", host, resp.StatusCode, repo)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse release of %s: %w", repo, err)
+	}
+	return nil
+}
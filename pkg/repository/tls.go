@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TrustCertificates makes the client trust the CA certificates in a PEM
+// file as well as the system's, as a TLS-intercepting proxy needs
+func (r *HttpRepository) TrustCertificates(pemFile string) error {
+	data, err := os.ReadFile(pemFile)
+	if err != nil {
+		return err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificates found in %s", pemFile)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	r.client.Transport = transport
+	return nil
+}
@@ -0,0 +1,120 @@
+package sealed
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+)
+
+// Sealed values look like ENC[age,<base64 age ciphertext>] so they fit on
+// one TOML line and can be committed alongside the rest of a config
+const (
+	prefix = "ENC[age,"
+	suffix = "]"
+)
+
+// IsSealed reports whether value is an encrypted value
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix) && strings.HasSuffix(value, suffix)
+}
+
+// Seal encrypts plaintext with the age CLI to the given recipients, or to
+// the identity's own recipient when there are none
+func Seal(plaintext string, identity string, recipients []string) (string, error) {
+	args := []string{"--encrypt"}
+	for _, recipient := range recipients {
+		args = append(args, "--recipient", recipient)
+	}
+	if len(recipients) == 0 {
+		args = append(args, "--identity", identity)
+	}
+
+	ciphertext, err := age(args, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return prefix + base64.StdEncoding.EncodeToString(ciphertext) + suffix, nil
+}
+
+// Unseal decrypts a sealed value with the age identity file
+func Unseal(value string, identity string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(value, prefix), suffix))
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	plaintext, err := age([]string{"--decrypt", "--identity", identity}, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Open replaces every sealed string reachable from v, a pointer to a
+// decoded config or manifest, with its plaintext. The age CLI is only
+// needed when something is sealed.
+func Open(v any, identity string) error {
+	return open(reflect.ValueOf(v).Elem(), identity)
+}
+
+func open(v reflect.Value, identity string) error {
+	switch v.Kind() {
+	case reflect.String:
+		if !IsSealed(v.String()) {
+			return nil
+		}
+		plaintext, err := Unseal(v.String(), identity)
+		if err != nil {
+			return err
+		}
+		v.SetString(plaintext)
+
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return open(v.Elem(), identity)
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				if err := open(v.Field(i), identity); err != nil {
+					return err
+				}
+			}
+		}
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := open(v.Index(i), identity); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		// Map values aren't addressable, so open a copy and store it back
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+			if err := open(value, identity); err != nil {
+				return fmt.Errorf("%v: %w", iter.Key(), err)
+			}
+			v.SetMapIndex(iter.Key(), value)
+		}
+	}
+	return nil
+}
+
+func age(args []string, stdin []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("age", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("age failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,72 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// fileStore is the opt-in fallback without a keychain: a plaintext TOML
+// file readable only by its owner
+type fileStore struct {
+	path string
+}
+
+func newFileStore(baseDir string) fileStore {
+	return fileStore{path: filepath.Join(baseDir, "credentials.toml")}
+}
+
+func (f fileStore) Name() string {
+	return f.path
+}
+
+func (f fileStore) load() (map[string]string, error) {
+	secrets := make(map[string]string)
+	if _, err := toml.DecodeFile(f.path, &secrets); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func (f fileStore) save(secrets map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return toml.NewEncoder(file).Encode(secrets)
+}
+
+func (f fileStore) Get(key string) (string, error) {
+	secrets, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (f fileStore) Set(key string, value string) error {
+	secrets, err := f.load()
+	if err != nil {
+		return err
+	}
+	secrets[key] = value
+	return f.save(secrets)
+}
+
+func (f fileStore) Delete(key string) error {
+	secrets, err := f.load()
+	if err != nil {
+		return err
+	}
+	delete(secrets, key)
+	return f.save(secrets)
+}
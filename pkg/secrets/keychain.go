@@ -0,0 +1,108 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychain keeps secrets as generic passwords in the macOS login keychain
+type keychain struct{}
+
+func (keychain) Name() string {
+	return "the macOS Keychain"
+}
+
+func (keychain) Get(key string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", key, "-w").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func (keychain) Set(key string, value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return errors.New("a secret for the macOS Keychain can't contain a newline")
+	}
+	// security -i reads its command from stdin, keeping the secret out of
+	// the process list. It carries on past a failed command, so anything on
+	// stderr is the failure.
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", securityQuote(service), securityQuote(key), securityQuote(value))
+	c := exec.Command("security", "-i")
+	c.Stdin = strings.NewReader(command)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("security failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stderr.Len() > 0 {
+		return fmt.Errorf("security failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// securityQuote quotes an argument for a security -i command line
+func securityQuote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+func (keychain) Delete(key string) error {
+	return run(nil, "security", "delete-generic-password", "-s", service, "-a", key)
+}
+
+// secretService keeps secrets in the freedesktop Secret Service (GNOME
+// Keyring, KWallet) through secret-tool
+type secretService struct{}
+
+func (secretService) Name() string {
+	return "the Secret Service keyring"
+}
+
+func (secretService) Get(key string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", key).Output()
+	// secret-tool exits 1 printing nothing when there is no match; any other
+	// failure, like a locked keyring or no D-Bus session, is an error
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(out) == 0 {
+		return "", ErrNotFound
+	}
+	if exitErr != nil {
+		return "", fmt.Errorf("secret-tool failed: %v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(out) == 0 {
+		return "", ErrNotFound
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func (secretService) Set(key string, value string) error {
+	// secret-tool reads the secret from stdin, keeping it out of the process list
+	return run(strings.NewReader(value), "secret-tool", "store", "--label", fmt.Sprintf("%s: %s", service, key), "service", service, "account", key)
+}
+
+func (secretService) Delete(key string) error {
+	return run(nil, "secret-tool", "clear", "service", service, "account", key)
+}
+
+func run(stdin *strings.Reader, name string, args ...string) error {
+	c := exec.Command(name, args...)
+	if stdin != nil {
+		c.Stdin = stdin
+	}
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
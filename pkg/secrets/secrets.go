@@ -0,0 +1,65 @@
+// Package secrets keeps credentials out of plaintext config, in the OS
+// keychain where there is one
+package secrets
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// service is what yourpm's entries are filed under in the keychain
+const service = "yourpm"
+
+// PlaintextEnv is the environment variable that allows keeping secrets in a
+// plaintext file when there is no keychain
+const PlaintextEnv = "YOURPM_PLAINTEXT_CREDENTIALS"
+
+// ErrNotFound is returned by Get when no secret is stored under a key
+var ErrNotFound = errors.New("secret not found")
+
+// ErrNoKeychain is returned by Default when there is no keychain and
+// plaintext secrets haven't been allowed
+var ErrNoKeychain = errors.New("no OS keychain available (on Linux, install secret-tool); set " + PlaintextEnv + "=1 to keep secrets in a plaintext file instead")
+
+// Store holds secrets by key, such as a host name
+type Store interface {
+	Get(key string) (string, error)
+	Set(key string, value string) error
+	Delete(key string) error
+	// Name describes where secrets are kept, for messages
+	Name() string
+}
+
+// Default picks the macOS Keychain, the Windows Credential Manager, or the
+// Secret Service through secret-tool on Linux. Without one it only falls
+// back to a file under baseDir, readable by its owner but not encrypted,
+// when PlaintextEnv is set, and returns ErrNoKeychain otherwise.
+func Default(baseDir string) (Store, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := exec.LookPath("security"); err == nil {
+			return keychain{}, nil
+		}
+	case "windows":
+		if store := credentialManager(); store != nil {
+			return store, nil
+		}
+	case "linux", "freebsd", "openbsd":
+		if _, err := exec.LookPath("secret-tool"); err == nil {
+			return secretService{}, nil
+		}
+	}
+
+	if allow := os.Getenv(PlaintextEnv); allow == "" || allow == "0" {
+		return nil, ErrNoKeychain
+	}
+	return newFileStore(baseDir), nil
+}
+
+// Plaintext reports whether a store keeps its secrets unencrypted on disk
+func Plaintext(store Store) bool {
+	_, ok := store.(fileStore)
+	return ok
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package secrets
+
+// credentialManager is only available on Windows
+func credentialManager() Store {
+	return nil
+}
@@ -0,0 +1,111 @@
+//go:build windows
+
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// winCredential is the Win32 CREDENTIALW struct
+type winCredential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// winCredentials keeps secrets as generic credentials in the Windows
+// Credential Manager, named "yourpm:<key>"
+type winCredentials struct{}
+
+func credentialManager() Store {
+	if advapi32.Load() != nil {
+		return nil
+	}
+	return winCredentials{}
+}
+
+func (winCredentials) Name() string {
+	return "the Windows Credential Manager"
+}
+
+func (winCredentials) Get(key string) (string, error) {
+	target, err := syscall.UTF16PtrFromString(service + ":" + key)
+	if err != nil {
+		return "", err
+	}
+
+	var cred *winCredential
+	ok, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ok == 0 {
+		if errors.Is(err, errorNotFound) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("CredRead failed: %w", err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func (winCredentials) Set(key string, value string) error {
+	target, err := syscall.UTF16PtrFromString(service + ":" + key)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(key)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(value)
+	cred := winCredential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if ok, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); ok == 0 {
+		return fmt.Errorf("CredWrite failed: %w", err)
+	}
+	return nil
+}
+
+func (winCredentials) Delete(key string) error {
+	target, err := syscall.UTF16PtrFromString(service + ":" + key)
+	if err != nil {
+		return err
+	}
+	if ok, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); ok == 0 && !errors.Is(err, errorNotFound) {
+		return fmt.Errorf("CredDelete failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+// Package snapshot keeps named copies of an applied environment, its
+// config, manifests and state, so it can be restored wholesale later
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/crbroughton/pkg-exploration/pkg/guard"
+)
+
+// Snapshot describes one saved environment
+type Snapshot struct {
+	Name        string            `toml:"name"`
+	CreatedAt   time.Time         `toml:"created_at"`
+	Environment string            `toml:"environment"`
+	Config      string            `toml:"config"`
+	Generation  int               `toml:"generation,omitempty"`
+	Packages    map[string]string `toml:"packages"`
+}
+
+// Sources are the files a snapshot copies
+type Sources struct {
+	State       string
+	Manifest    string
+	ManifestDir string
+}
+
+// Snapshots is a dir of snapshots, one <name>/ dir each holding
+// snapshot.toml, config.toml, state.toml, manifest.toml and manifest.d
+type Snapshots struct {
+	dir string
+}
+
+func New(dir string) *Snapshots {
+	return &Snapshots{dir: dir}
+}
+
+// Dir is where the named snapshot's files are kept
+func (s *Snapshots) Dir(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// Create saves snap, copying snap.Config and the sources into it. The
+// snapshot appears all at once or not at all.
+func (s *Snapshots) Create(snap Snapshot, src Sources) error {
+	if snap.Name == "" || snap.Name != filepath.Base(snap.Name) || snap.Name[0] == '.' {
+		return fmt.Errorf("invalid snapshot name %q", snap.Name)
+	}
+	if _, err := os.Stat(s.Dir(snap.Name)); err == nil {
+		return fmt.Errorf("snapshot %s already exists", snap.Name)
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(s.dir, ".tmp-"+snap.Name+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	copies := map[string]string{
+		snap.Config:  "config.toml",
+		src.State:    "state.toml",
+		src.Manifest: "manifest.toml",
+	}
+	for from, to := range copies {
+		if err := copyFile(from, filepath.Join(tmp, to)); err != nil {
+			return err
+		}
+	}
+
+	dropIns, err := filepath.Glob(filepath.Join(src.ManifestDir, "*.toml"))
+	if err != nil {
+		return err
+	}
+	for _, dropIn := range dropIns {
+		if err := copyFile(dropIn, filepath.Join(tmp, "manifest.d", filepath.Base(dropIn))); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(filepath.Join(tmp, "snapshot.toml"))
+	if err != nil {
+		return err
+	}
+	if err := toml.NewEncoder(f).Encode(snap); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.Dir(snap.Name))
+}
+
+// Get reads one snapshot
+func (s *Snapshots) Get(name string) (*Snapshot, error) {
+	var snap Snapshot
+	if _, err := toml.DecodeFile(filepath.Join(s.Dir(name), "snapshot.toml"), &snap); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("snapshot %s does not exist", name)
+		}
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", name, err)
+	}
+	return &snap, nil
+}
+
+// List returns every snapshot, oldest first
+func (s *Snapshots) List() ([]Snapshot, error) {
+	dirs, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, dir := range dirs {
+		if !dir.IsDir() || dir.Name()[0] == '.' {
+			continue
+		}
+		snap, err := s.Get(dir.Name())
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, *snap)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Delete removes a snapshot
+func (s *Snapshots) Delete(name string) error {
+	if _, err := s.Get(name); err != nil {
+		return err
+	}
+	return guard.RemoveAll(s.dir, s.Dir(name))
+}
+
+func copyFile(from string, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,46 @@
+package state
+
+import "sort"
+
+// Drift is a package that differs between two states. Here or There is nil
+// when only the other state has the package.
+type Drift struct {
+	Name  string
+	Here  *PackageState
+	There *PackageState
+}
+
+// Compare lists the packages whose version, URL or sha256 differ between
+// here and there, in name order. A URL or sha256 that either side doesn't
+// record, as when there came from a config, is not compared.
+func Compare(here *State, there *State) []Drift {
+	names := make(map[string]bool)
+	for name := range here.Packages {
+		names[name] = true
+	}
+	for name := range there.Packages {
+		names[name] = true
+	}
+
+	var drift []Drift
+	for name := range names {
+		a, inHere := here.Packages[name]
+		b, inThere := there.Packages[name]
+		switch {
+		case !inHere:
+			drift = append(drift, Drift{Name: name, There: &b})
+		case !inThere:
+			drift = append(drift, Drift{Name: name, Here: &a})
+		case a.Version != b.Version || differs(a.URL, b.URL) || differs(a.SHA256, b.SHA256):
+			drift = append(drift, Drift{Name: name, Here: &a, There: &b})
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool {
+		return drift[i].Name < drift[j].Name
+	})
+	return drift
+}
+
+func differs(a string, b string) bool {
+	return a != "" && b != "" && a != b
+}
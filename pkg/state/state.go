@@ -0,0 +1,198 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// State records what the last switch actually applied, as opposed to the
+// config which only says what the user wants
+type State struct {
+	Environment string                  `toml:"environment"`
+	Config      string                  `toml:"config"`
+	AppliedAt   time.Time               `toml:"applied_at"`
+	Packages    map[string]PackageState `toml:"packages"`
+
+	// Tags selected the config's [hosts."tag:..."] sections
+	Tags []string `toml:"tags,omitempty"`
+	// ConfigHash is the sha256 of the config as it was switched to
+	ConfigHash string `toml:"config_sha256,omitempty"`
+}
+
+// PackageState is the provenance of an installed package
+type PackageState struct {
+	Version      string    `toml:"version"`
+	URL          string    `toml:"url"`
+	SHA256       string    `toml:"sha256"`
+	Manifest     string    `toml:"manifest"`
+	DownloadedAt time.Time `toml:"downloaded_at"`
+	StorePath    string    `toml:"store_path"`
+	Binaries     []string  `toml:"binaries"`
+
+	// Env is the package's manifest env with placeholders resolved
+	Env map[string]string `toml:"env,omitempty"`
+	// DataDir is the package's writable dir, if its env uses one
+	DataDir string `toml:"data_dir,omitempty"`
+	// LibDir and CertFile are the shared libraries and CA bundle the
+	// package provides to others, if any
+	LibDir   string `toml:"lib_dir,omitempty"`
+	CertFile string `toml:"cert_file,omitempty"`
+}
+
+// Load reads the state file, returning empty state when nothing has been applied yet
+func Load(path string) (*State, error) {
+	s := State{Packages: make(map[string]PackageState)}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &s, nil
+	}
+
+	if _, err := toml.DecodeFile(path, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+	if s.Packages == nil {
+		s.Packages = make(map[string]PackageState)
+	}
+	return &s, nil
+}
+
+// Save writes the state atomically so a crash never leaves it half written
+func (s *State) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tempFile := path + ".tmp"
+	f, err := os.Create(tempFile)
+	if err != nil {
+		return err
+	}
+
+	if err := toml.NewEncoder(f).Encode(s); err != nil {
+		f.Close()
+		os.Remove(tempFile)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tempFile)
+		return err
+	}
+
+	return os.Rename(tempFile, path)
+}
+
+// Env merges the env of every package, in name order. It also returns a
+// warning for each variable more than one package sets, naming who wins.
+func (s *State) Env() (map[string]string, []string) {
+	names := make([]string, 0, len(s.Packages))
+	for name := range s.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make(map[string]string)
+	setBy := make(map[string]string)
+	var warnings []string
+	for _, name := range names {
+		for key, value := range s.Packages[name].Env {
+			if previous, ok := setBy[key]; ok {
+				warnings = append(warnings, fmt.Sprintf("%s is set by both %s and %s, using %s", key, previous, name, name))
+			}
+			env[key] = value
+			setBy[key] = name
+		}
+	}
+
+	// Every library package's dir goes on the search path; only one CA
+	// bundle can be used
+	var libDirs []string
+	for _, name := range names {
+		pkg := s.Packages[name]
+		if pkg.LibDir != "" {
+			libDirs = append(libDirs, pkg.LibDir)
+		}
+		if pkg.CertFile != "" {
+			if previous, ok := setBy[certFileVar]; ok {
+				warnings = append(warnings, fmt.Sprintf("%s is set by both %s and %s, using %s", certFileVar, previous, name, name))
+			}
+			env[certFileVar] = pkg.CertFile
+			setBy[certFileVar] = name
+		}
+	}
+	if len(libDirs) > 0 {
+		env[libraryPathVar()] = strings.Join(libDirs, string(os.PathListSeparator))
+	}
+	return env, warnings
+}
+
+// certFileVar points OpenSSL, and most TLS stacks that honour it, at a CA bundle
+const certFileVar = "SSL_CERT_FILE"
+
+// libraryPathVar is the variable the dynamic linker searches for the
+// libraries packages provide. macOS's fallback path is only searched after
+// a library's own install path, so system binaries are unaffected.
+func libraryPathVar() string {
+	if runtime.GOOS == "darwin" {
+		return "DYLD_FALLBACK_LIBRARY_PATH"
+	}
+	return "LD_LIBRARY_PATH"
+}
+
+// Owner finds the package that provides a command
+func (s *State) Owner(binary string) (string, bool) {
+	names := make([]string, 0, len(s.Packages))
+	for name := range s.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// States from before binaries were given a single owner can list one
+	// under several packages, the last in name order is the one linked
+	owner, found := "", false
+	for _, name := range names {
+		if slices.Contains(s.Packages[name].Binaries, binary) {
+			owner, found = name, true
+		}
+	}
+	return owner, found
+}
+
+// Matches reports whether the applied packages are exactly those wanted, at the wanted versions
+func (s *State) Matches(packages map[string]string) bool {
+	if len(packages) != len(s.Packages) {
+		return false
+	}
+	for name, version := range packages {
+		if pkg, ok := s.Packages[name]; !ok || pkg.Version != version {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash identifies the resolved environment: the name, version, URL and
+// sha256 of every package, in name order. Two machines with the same hash
+// run the same toolchain, whenever and wherever they applied it.
+func (s *State) Hash() string {
+	names := make([]string, 0, len(s.Packages))
+	for name := range s.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		pkg := s.Packages[name]
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\n", name, pkg.Version, pkg.URL, pkg.SHA256)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// appImageLauncher runs an extracted AppImage from next to where its link
+// resolves to, so it keeps working once the store entry is renamed into place
+const appImageLauncher = `#!/bin/sh
+# Generated by yourpm: runs the AppImage extracted next to this script
+here=$(dirname "$(readlink -f "$0")")
+exec "$here/%s.AppDir/AppRun" "$@"
+`
+
+func isAppImage(downloadPath string) bool {
+	return strings.HasSuffix(strings.ToLower(downloadPath), ".appimage")
+}
+
+// fuseAvailable reports whether an AppImage can mount itself, which is how
+// it normally runs
+func fuseAvailable() bool {
+	_, err := os.Stat("/dev/fuse")
+	return err == nil
+}
+
+// installAppImage extracts an AppImage with its own --appimage-extract, for
+// machines without FUSE such as containers, and installs a launcher for it
+// as binaryName
+func installAppImage(downloadPath string, storePath string, binaryName string) error {
+	tempDir := storePath + ".tmp"
+	if err := os.RemoveAll(tempDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	image := filepath.Join(tempDir, binaryName+".AppImage")
+	if err := copyFile(downloadPath, image); err != nil {
+		return err
+	}
+	if err := os.Chmod(image, 0755); err != nil {
+		return err
+	}
+	cmd := exec.Command(image, "--appimage-extract")
+	cmd.Dir = tempDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to extract AppImage: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	if err := os.MkdirAll(storePath, 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(tempDir, "squashfs-root"), filepath.Join(storePath, binaryName+".AppDir")); err != nil {
+		return fmt.Errorf("AppImage extracted nothing: %w", err)
+	}
+	return os.WriteFile(filepath.Join(storePath, binaryName), []byte(fmt.Sprintf(appImageLauncher, binaryName)), 0755)
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ArchiveHandler unpacks one kind of download. Install asks each registered
+// handler in turn whether it recognises a download, and copies it as a
+// plain binary if none does.
+type ArchiveHandler interface {
+	// Detect reports whether the handler unpacks the download at path
+	Detect(path string) bool
+	// Extract unpacks the download at path into dir, which exists and is empty
+	Extract(path string, dir string) error
+	// ListBinaries names the executables Extract left under dir, relative
+	// to it, for telling the user what an archive has when a binary the
+	// manifest names isn't there
+	ListBinaries(dir string) ([]string, error)
+}
+
+var (
+	handlersMu sync.RWMutex
+	handlers   []ArchiveHandler
+)
+
+// RegisterArchiveHandler adds a handler for another archive format. Later
+// registrations are asked first, so one can take over a format from the
+// built-in handlers.
+func RegisterArchiveHandler(handler ArchiveHandler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers = append([]ArchiveHandler{handler}, handlers...)
+}
+
+// handlerFor is the handler that unpacks the download at path, or nil if
+// it isn't an archive
+func handlerFor(path string) ArchiveHandler {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	for _, handler := range handlers {
+		if handler.Detect(path) {
+			return handler
+		}
+	}
+	return nil
+}
+
+// notFoundError says a binary is missing from an extracted archive, and
+// what it has instead
+func notFoundError(handler ArchiveHandler, dir string, binaryName string) error {
+	found, err := handler.ListBinaries(dir)
+	if err != nil || len(found) == 0 {
+		return fmt.Errorf("binary %s not found in archive", binaryName)
+	}
+	return fmt.Errorf("binary %s not found in archive, which has: %s", binaryName, strings.Join(found, ", "))
+}
+
+// Executables lists the regular files under dir with an executable bit
+// set, relative to it. Handlers can use it as their ListBinaries.
+func Executables(dir string) ([]string, error) {
+	var found []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			found = append(found, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(found)
+	return found, err
+}
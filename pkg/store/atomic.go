@@ -0,0 +1,57 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// completeMarker is written last into a store entry, so its presence means
+// every file before it made it to disk
+const completeMarker = ".complete"
+
+// markComplete syncs every file in dir and then writes the marker
+func markComplete(dir string) error {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return syncFile(path)
+	})
+	if err != nil {
+		return err
+	}
+
+	marker := filepath.Join(dir, completeMarker)
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		return err
+	}
+	if err := syncFile(marker); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// syncDir persists a directory's entries, so renames into it survive a crash
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// Some platforms can't sync directories, the rename is still atomic there
+	d.Sync()
+	return nil
+}
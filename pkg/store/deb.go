@@ -0,0 +1,72 @@
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterArchiveHandler(debHandler{})
+}
+
+// debHandler unpacks the files a .deb installs, its data.tar member, without
+// needing dpkg. Maintainer scripts in control.tar are not run.
+type debHandler struct{}
+
+func (debHandler) Detect(path string) bool {
+	return strings.HasSuffix(path, ".deb")
+}
+
+func (debHandler) Extract(path string, dir string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	magic := make([]byte, 8)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != "!<arch>\n" {
+		return fmt.Errorf("not a deb package: missing ar header")
+	}
+
+	// An ar member is a 60 byte header, its data, and a pad byte to an even offset
+	header := make([]byte, 60)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("deb package has no data.tar member")
+			}
+			return fmt.Errorf("failed to read deb package: %w", err)
+		}
+		name := strings.TrimSuffix(strings.TrimSpace(string(header[0:16])), "/")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad size for deb member %s", name)
+		}
+
+		if strings.HasPrefix(name, "data.tar") {
+			payload, err := decompress(io.LimitReader(r, size))
+			if err != nil {
+				return fmt.Errorf("failed to decompress %s: %w", name, err)
+			}
+			if err := untar(payload, dir); err != nil {
+				payload.Close()
+				return err
+			}
+			return payload.Close()
+		}
+
+		if _, err := r.Discard(int(size + size%2)); err != nil {
+			return fmt.Errorf("failed to read deb package: %w", err)
+		}
+	}
+}
+
+func (debHandler) ListBinaries(dir string) ([]string, error) {
+	return Executables(dir)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package store
+
+import "errors"
+
+// FreeSpace is not implemented on this platform, callers should skip their check
+func FreeSpace(path string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entry is an installed package version in the store
+type Entry struct {
+	Name        string
+	Version     string
+	Path        string
+	InstalledAt time.Time
+	// Complete is false for a dir without the marker a finished install
+	// leaves: one installed before the marker existed, or one an
+	// interrupted install left behind. Neither is reused, only removed.
+	Complete bool
+}
+
+// Entries lists the installed versions of the named packages, newest first.
+// Store dirs are named <name>-<version>, so a dir is given to the longest
+// name it starts with; dirs matching none of names, and staging dirs of
+// installs that may still be running, are left out.
+func (s *Store) Entries(names []string) ([]Entry, error) {
+	dirs, err := os.ReadDir(s.root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, dir := range dirs {
+		if !dir.IsDir() || hasLeftoverSuffix(dir.Name()) {
+			continue
+		}
+
+		owner := ""
+		for _, name := range names {
+			if strings.HasPrefix(dir.Name(), name+"-") && len(name) > len(owner) {
+				owner = name
+			}
+		}
+		if owner == "" {
+			continue
+		}
+
+		path := filepath.Join(s.root, dir.Name())
+		entry := Entry{
+			Name:    owner,
+			Version: strings.TrimPrefix(dir.Name(), owner+"-"),
+			Path:    path,
+		}
+		if marker, err := os.Stat(filepath.Join(path, completeMarker)); err == nil {
+			entry.InstalledAt = marker.ModTime()
+			entry.Complete = true
+		} else if info, err := dir.Info(); err == nil {
+			entry.InstalledAt = info.ModTime()
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].InstalledAt.After(entries[j].InstalledAt)
+	})
+	return entries, nil
+}
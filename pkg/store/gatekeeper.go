@@ -0,0 +1,31 @@
+package store
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Unquarantine strips macOS's com.apple.quarantine attribute from
+// everything under dir, which otherwise makes Gatekeeper refuse to run
+// binaries that came from the internet
+func Unquarantine(dir string) error {
+	out, err := exec.Command("xattr", "-dr", "com.apple.quarantine", dir).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("xattr failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// AdhocSign signs the binaries in dir with an ad-hoc signature, which
+// Apple Silicon needs before it will run a binary at all
+func AdhocSign(dir string, binaries []string) error {
+	for _, binary := range binaries {
+		out, err := exec.Command("codesign", "--force", "--sign", "-", filepath.Join(dir, binary)).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("codesign %s failed: %v: %s", binary, err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Leftover is a temp file or dir an interrupted run left behind
+type Leftover struct {
+	Path string
+	Size int64
+}
+
+// leftoverSuffixes are what downloads, extractions, staging dirs and
+// atomic writes are named while in progress
+var leftoverSuffixes = []string{".tmp", ".partial", ".unverified"}
+
+// Leftovers lists the temp artifacts directly in dir that nothing has
+// touched for olderThan. A download or extraction still running keeps
+// writing, so anything in use has a recent modification time somewhere
+// inside it and is left out.
+func Leftovers(dir string, olderThan time.Duration) ([]Leftover, error) {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var leftovers []Leftover
+	for _, file := range files {
+		if !hasLeftoverSuffix(file.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		size, newest := usage(path)
+		if newest.After(cutoff) {
+			continue
+		}
+		leftovers = append(leftovers, Leftover{Path: path, Size: size})
+	}
+	return leftovers, nil
+}
+
+func hasLeftoverSuffix(name string) bool {
+	for _, suffix := range leftoverSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// DiskUsage is the total size of the files under path
+func DiskUsage(path string) int64 {
+	size, _ := usage(path)
+	return size
+}
+
+// usage is the total size of the files under path and the latest time
+// anything there was modified
+func usage(path string) (int64, time.Time) {
+	var size int64
+	var newest time.Time
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, newest
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// findAndMoveLibrary searches the extracted tree for a library or CA bundle
+// and copies it into libDir. Libraries are usually symlinks to a versioned
+// file (libssl.so.3 -> libssl.so.3.0.13), so the link is resolved and the
+// file it points to is copied under the name asked for.
+func findAndMoveLibrary(tempDir string, libDir string, library string) (bool, error) {
+	var foundPath string
+	var suffixed []string
+	err := filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if filepath.Base(path) == library {
+			foundPath = path
+			return filepath.SkipAll
+		}
+		if hasVersionSuffix(filepath.Base(path), library) {
+			suffixed = append(suffixed, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if foundPath == "" && len(suffixed) == 1 {
+		foundPath = suffixed[0]
+	}
+	if foundPath == "" {
+		return false, nil
+	}
+
+	resolved, err := resolveInside(tempDir, foundPath)
+	if err != nil {
+		return false, err
+	}
+	if err := installLibrary(resolved, filepath.Join(libDir, library)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// installLibrary copies a library to dest keeping its permissions
+func installLibrary(src string, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := copyFile(src, dest); err != nil {
+		return err
+	}
+	return os.Chmod(dest, info.Mode().Perm()|0444)
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+func init() {
+	RegisterArchiveHandler(dmgHandler{})
+	RegisterArchiveHandler(pkgHandler{})
+}
+
+// dmgHandler copies the contents of a disk image, attached read-only with
+// hdiutil and detached again afterwards
+type dmgHandler struct{}
+
+func (dmgHandler) Detect(path string) bool {
+	return strings.HasSuffix(path, ".dmg")
+}
+
+func (dmgHandler) Extract(path string, dir string) error {
+	if runtime.GOOS != "darwin" {
+		return fmt.Errorf("dmg images can only be opened on macOS")
+	}
+
+	mountPoint, err := os.MkdirTemp("", "yourpm-dmg-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(mountPoint)
+
+	attach := exec.Command("hdiutil", "attach", "-nobrowse", "-readonly", "-noautoopen", "-mountpoint", mountPoint, path)
+	// Images with a license agreement wait for it to be accepted
+	attach.Stdin = strings.NewReader("Y\n")
+	if out, err := attach.CombinedOutput(); err != nil {
+		return fmt.Errorf("hdiutil attach failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	defer func() {
+		if exec.Command("hdiutil", "detach", mountPoint).Run() != nil {
+			exec.Command("hdiutil", "detach", "-force", mountPoint).Run()
+		}
+	}()
+
+	out, err := exec.Command("cp", "-R", mountPoint+"/.", dir).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to copy from dmg: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (dmgHandler) ListBinaries(dir string) ([]string, error) {
+	return Executables(dir)
+}
+
+// pkgHandler unpacks the payloads of a flat installer package with pkgutil,
+// without installing it or running its scripts
+type pkgHandler struct{}
+
+func (pkgHandler) Detect(path string) bool {
+	return strings.HasSuffix(path, ".pkg")
+}
+
+func (pkgHandler) Extract(path string, dir string) error {
+	if runtime.GOOS != "darwin" {
+		return fmt.Errorf("installer packages can only be unpacked on macOS")
+	}
+
+	// pkgutil wants to create the destination itself
+	out, err := exec.Command("pkgutil", "--expand-full", path, filepath.Join(dir, "expanded")).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pkgutil failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (pkgHandler) ListBinaries(dir string) ([]string, error) {
+	return Executables(dir)
+}
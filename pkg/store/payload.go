@@ -0,0 +1,60 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// decompress recognises the compression of a package payload by its magic
+// bytes and returns the uncompressed stream. Gzip and bzip2 are read in
+// process; xz and zstd go through their command line tools, as tar does.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(6)
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return io.NopCloser(bzip2.NewReader(br)), nil
+	case bytes.HasPrefix(magic, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return pipeThrough(br, "xz", "-dc")
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return pipeThrough(br, "zstd", "-dc")
+	default:
+		return io.NopCloser(br), nil
+	}
+}
+
+// pipeThrough streams r through a command; closing the result waits for it
+func pipeThrough(r io.Reader, name string, args ...string) (io.ReadCloser, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = r
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", name, err)
+	}
+	return &commandReader{ReadCloser: out, cmd: cmd}, nil
+}
+
+type commandReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (c *commandReader) Close() error {
+	// Drain what's left so the command doesn't block writing it
+	io.Copy(io.Discard, c.ReadCloser)
+	if err := c.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s failed: %w", c.cmd.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterArchiveHandler(rpmHandler{})
+}
+
+// rpmHandler unpacks the files an .rpm installs, its cpio payload, without
+// needing rpm. Scriptlets are not run.
+type rpmHandler struct{}
+
+func (rpmHandler) Detect(path string) bool {
+	return strings.HasSuffix(path, ".rpm")
+}
+
+func (rpmHandler) Extract(path string, dir string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	lead := make([]byte, 96)
+	if _, err := io.ReadFull(r, lead); err != nil || string(lead[:4]) != "\xed\xab\xee\xdb" {
+		return fmt.Errorf("not an rpm package: missing lead")
+	}
+	// The signature header is padded to 8 bytes, the main header isn't
+	if err := skipRPMHeader(r, true); err != nil {
+		return fmt.Errorf("bad rpm signature header: %w", err)
+	}
+	if err := skipRPMHeader(r, false); err != nil {
+		return fmt.Errorf("bad rpm header: %w", err)
+	}
+
+	payload, err := decompress(r)
+	if err != nil {
+		return fmt.Errorf("failed to decompress rpm payload: %w", err)
+	}
+	if err := uncpio(payload, dir); err != nil {
+		payload.Close()
+		return err
+	}
+	return payload.Close()
+}
+
+func (rpmHandler) ListBinaries(dir string) ([]string, error) {
+	return Executables(dir)
+}
+
+// skipRPMHeader reads past a header structure: magic, reserved bytes, the
+// index entry count and data size, then the index and data themselves
+func skipRPMHeader(r *bufio.Reader, padded bool) error {
+	intro := make([]byte, 16)
+	if _, err := io.ReadFull(r, intro); err != nil {
+		return err
+	}
+	if string(intro[:3]) != "\x8e\xad\xe8" {
+		return fmt.Errorf("bad magic")
+	}
+	entries := binary.BigEndian.Uint32(intro[8:12])
+	size := int(entries)*16 + int(binary.BigEndian.Uint32(intro[12:16]))
+	if padded {
+		size += (8 - (16+size)%8) % 8
+	}
+	_, err := r.Discard(size)
+	return err
+}
+
+// uncpio unpacks the directories and regular files of a cpio stream in the
+// newc format rpm uses into destDir
+func uncpio(r io.Reader, destDir string) error {
+	br := bufio.NewReader(r)
+	header := make([]byte, 110)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			return fmt.Errorf("failed to read cpio: %w", err)
+		}
+		if magic := string(header[:6]); magic != "070701" && magic != "070702" {
+			return fmt.Errorf("unsupported cpio format %q", magic)
+		}
+		field := func(i int) (int64, error) {
+			return strconv.ParseInt(string(header[6+8*i:14+8*i]), 16, 64)
+		}
+		mode, err := field(1)
+		if err != nil {
+			return fmt.Errorf("bad cpio header")
+		}
+		fileSize, err := field(6)
+		if err != nil {
+			return fmt.Errorf("bad cpio header")
+		}
+		nameSize, err := field(11)
+		if err != nil || nameSize == 0 {
+			return fmt.Errorf("bad cpio header")
+		}
+
+		// The name and the data are each padded to 4 bytes
+		nameBuf := make([]byte, nameSize+(4-(110+nameSize)%4)%4)
+		if _, err := io.ReadFull(br, nameBuf); err != nil {
+			return fmt.Errorf("failed to read cpio: %w", err)
+		}
+		name := strings.TrimRight(string(nameBuf[:nameSize]), "\x00")
+		if name == "TRAILER!!!" {
+			return nil
+		}
+		padding := (4 - fileSize%4) % 4
+
+		rel := filepath.Clean(strings.TrimPrefix(name, "/"))
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("cpio entry %q is outside the archive", name)
+		}
+		target := filepath.Join(destDir, rel)
+
+		switch mode & 0170000 {
+		case 0040000:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case 0100000:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			outFile, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(mode&0777))
+			if err != nil {
+				return err
+			}
+			if _, err := io.CopyN(outFile, br, fileSize); err != nil {
+				outFile.Close()
+				return err
+			}
+			outFile.Close()
+			fileSize = 0
+		}
+
+		if _, err := br.Discard(int(fileSize + padding)); err != nil {
+			return fmt.Errorf("failed to read cpio: %w", err)
+		}
+	}
+}
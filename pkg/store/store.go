@@ -1,14 +1,15 @@
 package store
 
 import (
-	"archive/tar"
-	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
+
+	"github.com/crbroughton/pkg-exploration/pkg/guard"
+	"github.com/crbroughton/pkg-exploration/pkg/manifest"
 )
 
 type Store struct {
@@ -21,125 +22,115 @@ func NewStore(root string) *Store {
 	}
 }
 
-func (s *Store) Install(name string, version string, downloadPath string, binaryNames []string) (string, error) {
-	storePath := filepath.Join(s.root, fmt.Sprintf("%s-%s", name, version))
-	if _, err := os.Stat(storePath); err == nil {
-		return storePath, nil
-	}
+func (s *Store) Root() string {
+	return s.root
+}
 
-	extension := filepath.Ext(downloadPath)
-	switch {
-	case strings.HasSuffix(downloadPath, ".tar.gz") || extension == ".tgz":
-		return s.installTarGz(downloadPath, storePath, binaryNames)
-	case strings.HasSuffix(downloadPath, ".tar.xz"):
-		return s.installTarXz(downloadPath, storePath, binaryNames)
-	default:
-		return s.installBinary(name, downloadPath, storePath)
-	}
+// Path is where a package version lives in the store, installed or not
+func (s *Store) Path(name string, version string) string {
+	return filepath.Join(s.root, fmt.Sprintf("%s-%s", name, version))
 }
 
-func (s *Store) installBinary(name string, downloadPath string, storePath string) (string, error) {
-	if err := os.MkdirAll(storePath, 0755); err != nil {
-		return "", err
+// Install puts a package into the store: its binaries at the top of the
+// entry and any libraries in its lib dir. The entry is built in a staging
+// dir, synced to disk, marked complete and renamed into place, so a crash
+// never leaves a store path that looks installed but isn't.
+func (s *Store) Install(name string, version string, downloadPath string, binaryNames []string, libraries []string) (string, error) {
+	storePath := s.Path(name, version)
+	if s.Installed(name, version) {
+		return storePath, nil
 	}
 
-	destPath := filepath.Join(storePath, name)
-	if err := copyFile(downloadPath, destPath); err != nil {
+	// Anything at storePath without a marker is left over from an interrupted install
+	if err := guard.RemoveAll(s.root, storePath); err != nil {
 		return "", err
 	}
 
-	if err := os.Chmod(destPath, 0755); err != nil {
+	staging := storePath + ".partial"
+	if err := os.RemoveAll(staging); err != nil {
 		return "", err
 	}
+	defer os.RemoveAll(staging)
 
-	return storePath, nil
-}
-
-func (s *Store) installTarGz(downloadPath string, storePath string, binaryNames []string) (string, error) {
-	tempDir := storePath + ".tmp"
-	if err := os.RemoveAll(tempDir); err != nil {
+	if err := s.populate(name, downloadPath, staging, binaryNames, libraries); err != nil {
 		return "", err
 	}
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		return "", err
-	}
-	defer os.RemoveAll(tempDir)
 
-	if err := s.extractTarGz(downloadPath, tempDir); err != nil {
+	if err := markComplete(staging); err != nil {
 		return "", err
 	}
-
-	if err := os.MkdirAll(storePath, 0755); err != nil {
+	if err := os.Rename(staging, storePath); err != nil {
 		return "", err
 	}
-
-	for _, binaryName := range binaryNames {
-		found, err := s.findAndMoveBinary(tempDir, storePath, binaryName)
-		if err != nil {
-			return "", err
-		}
-		if !found {
-			return "", fmt.Errorf("binary %s not found in archive", binaryName)
-		}
+	if err := syncDir(s.root); err != nil {
+		return "", err
 	}
 
 	return storePath, nil
 }
 
-func (s *Store) extractTarGz(downloadPath string, destDir string) error {
-	file, err := os.Open(downloadPath)
-	if err != nil {
-		return err
+// Installed reports whether a package version finished installing
+func (s *Store) Installed(name string, version string) bool {
+	_, err := os.Stat(filepath.Join(s.Path(name, version), completeMarker))
+	return err == nil
+}
+
+// Remove deletes a package version from the store, installed or not
+func (s *Store) Remove(name string, version string) error {
+	return guard.RemoveAll(s.root, s.Path(name, version))
+}
+
+func (s *Store) populate(name string, downloadPath string, storePath string, binaryNames []string, libraries []string) error {
+	var err error
+	switch handler := handlerFor(downloadPath); {
+	case handler != nil:
+		_, err = s.installArchive(downloadPath, storePath, binaryNames, libraries, handler)
+	case len(binaryNames) == 0 && len(libraries) == 1:
+		// A library or CA bundle published as a file of its own
+		err = installLibrary(downloadPath, filepath.Join(storePath, manifest.LibDir, libraries[0]))
+	case isAppImage(downloadPath) && !fuseAvailable():
+		err = installAppImage(downloadPath, storePath, SingleBinaryName(name, binaryNames))
+	default:
+		_, err = s.installBinary(SingleBinaryName(name, binaryNames), downloadPath, storePath)
 	}
-	defer file.Close()
+	return err
+}
 
-	gzr, err := gzip.NewReader(file)
-	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
+// SingleBinaryName is what a download that is the binary itself is
+// installed as: the manifest's binary name if it lists just one, since
+// release assets are often named tool-v1.2.3-linux-amd64, or else the
+// package name
+func SingleBinaryName(name string, binaryNames []string) string {
+	if len(binaryNames) == 1 {
+		return binaryNames[0]
 	}
-	defer gzr.Close()
+	return name
+}
 
-	tr := tar.NewReader(gzr)
+// IsArchive reports whether Install will extract the download rather than copy it
+func IsArchive(downloadPath string) bool {
+	return handlerFor(downloadPath) != nil
+}
 
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read tar: %w", err)
-		}
+func (s *Store) installBinary(name string, downloadPath string, storePath string) (string, error) {
+	if err := os.MkdirAll(storePath, 0755); err != nil {
+		return "", err
+	}
 
-		target := filepath.Join(destDir, header.Name)
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(target, 0755); err != nil {
-				return err
-			}
-
-		case tar.TypeReg:
-			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-				return err
-			}
-
-			outFile, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(header.Mode))
-			if err != nil {
-				return err
-			}
-
-			if _, err := io.Copy(outFile, tr); err != nil {
-				outFile.Close()
-				return err
-			}
-			outFile.Close()
-		}
+	destPath := filepath.Join(storePath, name)
+	if err := copyFile(downloadPath, destPath); err != nil {
+		return "", err
+	}
+
+	if err := os.Chmod(destPath, 0755); err != nil {
+		return "", err
 	}
 
-	return nil
+	return storePath, nil
 }
 
-func (s *Store) installTarXz(downloadPath string, storePath string, binaryNames []string) (string, error) {
+// installArchive extracts an archive to a temp dir and moves the binaries out of it
+func (s *Store) installArchive(downloadPath string, storePath string, binaryNames []string, libraries []string, handler ArchiveHandler) (string, error) {
 	tempDir := storePath + ".tmp"
 	if err := os.RemoveAll(tempDir); err != nil {
 		return "", err
@@ -149,7 +140,7 @@ func (s *Store) installTarXz(downloadPath string, storePath string, binaryNames
 	}
 	defer os.RemoveAll(tempDir)
 
-	if err := s.extractTarXz(downloadPath, tempDir); err != nil {
+	if err := handler.Extract(downloadPath, tempDir); err != nil {
 		return "", err
 	}
 
@@ -163,26 +154,29 @@ func (s *Store) installTarXz(downloadPath string, storePath string, binaryNames
 			return "", err
 		}
 		if !found {
-			return "", fmt.Errorf("binary %s not found in archive", binaryName)
+			return "", notFoundError(handler, tempDir, binaryName)
+		}
+	}
+	for _, library := range libraries {
+		found, err := findAndMoveLibrary(tempDir, filepath.Join(storePath, manifest.LibDir), library)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return "", fmt.Errorf("library %s not found in archive", library)
 		}
 	}
 
 	return storePath, nil
 }
 
-func (s *Store) extractTarXz(downloadPath string, destDir string) error {
-	// Use tar command to extract .tar.xz
-	// tar automatically detects xz compression
-	cmd := exec.Command("tar", "-xJf", downloadPath, "-C", destDir)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to extract tar.xz: %w", err)
-	}
-	return nil
-}
-
-// findAndMoveBinary searches the temp directory tree for the binary and moves it to store root
+// findAndMoveBinary searches the temp directory tree for the binary and moves
+// it to store root. Failing an exact match it takes the one file named for
+// the binary with a version or platform suffix, like tool-v1.2.3-linux-amd64,
+// and renames it.
 func (s *Store) findAndMoveBinary(tempDir string, storePath string, binaryName string) (bool, error) {
 	var foundPath string
+	var suffixed []string
 
 	// Walk the temp directory tree
 	err := filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
@@ -199,6 +193,9 @@ func (s *Store) findAndMoveBinary(tempDir string, storePath string, binaryName s
 			foundPath = path
 			return filepath.SkipAll // Stop walking once found
 		}
+		if hasVersionSuffix(filepath.Base(path), binaryName) {
+			suffixed = append(suffixed, path)
+		}
 
 		return nil
 	})
@@ -207,17 +204,34 @@ func (s *Store) findAndMoveBinary(tempDir string, storePath string, binaryName s
 		return false, err
 	}
 
+	if foundPath == "" && len(suffixed) == 1 {
+		foundPath = suffixed[0]
+	}
 	if foundPath == "" {
 		return false, nil
 	}
 
+	// A binary that is a link is copied from its target, which another
+	// binary may link to as well
+	resolved, err := resolveInside(tempDir, foundPath)
+	if err != nil {
+		return false, err
+	}
 	destPath := filepath.Join(storePath, binaryName)
-	if err := os.Rename(foundPath, destPath); err != nil {
-		if err := copyFile(foundPath, destPath); err != nil {
+	if resolved != foundPath || os.Rename(resolved, destPath) != nil {
+		if err := copyFile(resolved, destPath); err != nil {
 			return false, err
 		}
 	}
 
+	// Only ever chmod the file just written, never through a link
+	info, err := os.Lstat(destPath)
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() {
+		return false, fmt.Errorf("%s is not a regular file", destPath)
+	}
 	if err := os.Chmod(destPath, 0755); err != nil {
 		return false, err
 	}
@@ -225,6 +239,46 @@ func (s *Store) findAndMoveBinary(tempDir string, storePath string, binaryName s
 	return true, nil
 }
 
+// resolveInside follows a symlink found in an extracted archive to the
+// regular file it names, refusing one that leads out of the archive
+func resolveInside(tempDir string, path string) (string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	target := path
+	if info.Mode()&os.ModeSymlink != 0 {
+		if target, err = filepath.EvalSymlinks(path); err != nil {
+			return "", err
+		}
+		if err := guard.Check(tempDir, target); err != nil {
+			return "", fmt.Errorf("%s links outside the archive", filepath.Base(path))
+		}
+		if info, err = os.Lstat(target); err != nil {
+			return "", err
+		}
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", filepath.Base(path))
+	}
+	return target, nil
+}
+
+// hasVersionSuffix reports whether file is binaryName followed by a
+// separator and a version or platform, and not some other file like
+// tool-completion.bash or tool.1
+func hasVersionSuffix(file string, binaryName string) bool {
+	rest, ok := strings.CutPrefix(file, binaryName)
+	if !ok || rest == "" || !strings.ContainsRune("-_.", rune(rest[0])) {
+		return false
+	}
+	return versionOrPlatform.MatchString(rest[1:])
+}
+
+// versionOrPlatform is how a suffix naming a release build starts: a
+// version, or an OS or architecture
+var versionOrPlatform = regexp.MustCompile(`^(v?\d+\.\d|(linux|darwin|macos|windows|freebsd|amd64|x86_64|arm64|aarch64)([-_.]|$))`)
+
 func copyFile(src string, dest string) error {
 	source, err := os.Open(src)
 	if err != nil {
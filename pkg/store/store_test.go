@@ -0,0 +1,60 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFindAndMoveBinarySymlink checks that a binary shipped as a symlink is
+// installed from its target, and only when that target is in the archive
+func TestFindAndMoveBinarySymlink(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(root, "outside")
+	if err := os.WriteFile(outside, []byte("not yours"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tempDir := filepath.Join(root, "tmp")
+	if err := os.MkdirAll(filepath.Join(tempDir, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "bin", "tool"), []byte("tool"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("tool", filepath.Join(tempDir, "bin", "alias")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(tempDir, "bin", "escape")); err != nil {
+		t.Fatal(err)
+	}
+	storePath := filepath.Join(root, "store")
+	if err := os.Mkdir(storePath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore(root)
+
+	if found, err := s.findAndMoveBinary(tempDir, storePath, "alias"); !found || err != nil {
+		t.Fatalf("alias: found %v, %v", found, err)
+	}
+	info, err := os.Lstat(filepath.Join(storePath, "alias"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm() != 0755 {
+		t.Errorf("alias installed as %v, want an executable regular file", info.Mode())
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "bin", "tool")); err != nil {
+		t.Errorf("the target of alias was moved: %v", err)
+	}
+
+	if _, err := s.findAndMoveBinary(tempDir, storePath, "escape"); err == nil {
+		t.Error("escape links outside the archive but was installed")
+	}
+	info, err = os.Stat(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("the file escape links to was chmodded to %v", info.Mode())
+	}
+}
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	RegisterArchiveHandler(tarHandler{suffixes: []string{".tar.gz", ".tgz"}, extract: extractTarGz})
+	RegisterArchiveHandler(tarHandler{suffixes: []string{".tar.xz"}, extract: extractTarXz})
+	RegisterArchiveHandler(tarHandler{suffixes: []string{".tar.zst", ".tzst"}, extract: extractTarZst})
+}
+
+// tarHandler unpacks a compressed tarball, recognised by its suffix. Every
+// tarball is unpacked by untar, whichever tool decompresses it, so symlinks
+// and permissions come out the same on every host.
+type tarHandler struct {
+	suffixes []string
+	extract  func(path string, dir string) error
+}
+
+func (h tarHandler) Detect(path string) bool {
+	for _, suffix := range h.suffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h tarHandler) Extract(path string, dir string) error {
+	return h.extract(path, dir)
+}
+
+func (h tarHandler) ListBinaries(dir string) ([]string, error) {
+	return Executables(dir)
+}
+
+func extractTarGz(downloadPath string, destDir string) error {
+	file, err := os.Open(downloadPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// pigz decompresses on several threads, which matters for big toolchains
+	if _, err := exec.LookPath("pigz"); err == nil {
+		return untarThrough(file, destDir, "pigz", "-dc")
+	}
+
+	gzr, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer gzr.Close()
+
+	return untar(gzr, destDir)
+}
+
+func extractTarXz(downloadPath string, destDir string) error {
+	file, err := os.Open(downloadPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return untarThrough(file, destDir, "xz", "-dc")
+}
+
+func extractTarZst(downloadPath string, destDir string) error {
+	file, err := os.Open(downloadPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return untarThrough(file, destDir, "zstd", "-dc")
+}
+
+// untarThrough decompresses r with a command and unpacks the result
+func untarThrough(r io.Reader, destDir string, name string, args ...string) error {
+	payload, err := pipeThrough(r, name, args...)
+	if err != nil {
+		return err
+	}
+	if err := untar(payload, destDir); err != nil {
+		payload.Close()
+		return err
+	}
+	return payload.Close()
+}
+
+// untar unpacks the directories, regular files, symlinks and hard links of
+// an uncompressed tar stream into destDir. Permission bits are kept, less
+// the umask, as tar does for an unprivileged user; entries that would land
+// outside destDir are refused.
+func untar(r io.Reader, destDir string) error {
+	tr := tar.NewReader(r)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar: %w", err)
+		}
+
+		rel := filepath.Clean(strings.TrimPrefix(header.Name, "/"))
+		if rel == "." {
+			continue
+		}
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("tar entry %q is outside the archive", header.Name)
+		}
+		if err := checkParents(destDir, rel); err != nil {
+			return err
+		}
+		target := filepath.Join(destDir, rel)
+		mode := os.FileMode(header.Mode).Perm()
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			// A link unpacked earlier must not be followed out of destDir
+			if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+				if err := os.Remove(target); err != nil {
+					return err
+				}
+			}
+			// Keep directories writable so their contents can still be unpacked
+			if err := os.MkdirAll(target, mode|0700); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, mode|0700); err != nil {
+				return err
+			}
+
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			// A link unpacked earlier must not be written through
+			if err := removeExisting(target); err != nil {
+				return err
+			}
+
+			outFile, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+
+			if _, err := io.Copy(outFile, tr); err != nil {
+				outFile.Close()
+				return err
+			}
+			outFile.Close()
+
+		case tar.TypeSymlink:
+			// Links may be relative or absolute, as tar allows, but they
+			// are never followed while unpacking
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := removeExisting(target); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+
+		case tar.TypeLink:
+			source := filepath.Clean(strings.TrimPrefix(header.Linkname, "/"))
+			if !filepath.IsLocal(source) {
+				return fmt.Errorf("tar entry %q links outside the archive", header.Name)
+			}
+			if err := checkParents(destDir, source); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := removeExisting(target); err != nil {
+				return err
+			}
+			if err := os.Link(filepath.Join(destDir, source), target); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkParents refuses an entry under a symlink unpacked earlier, which
+// could otherwise point it anywhere
+func checkParents(destDir string, rel string) error {
+	dir := destDir
+	for _, part := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if part == "." {
+			break
+		}
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("tar entry %q is under a symlink", rel)
+		}
+	}
+	return nil
+}
+
+// removeExisting clears the way for an entry, leaving directories alone
+func removeExisting(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,219 @@
+package store
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// benchTarball is a tarball shaped like a small toolchain: a few dirs of
+// binaries and libraries, with a symlink
+func benchTarball(tb testing.TB) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	data := bytes.Repeat([]byte("yourpm benchmark payload\n"), 4096)
+
+	for d := 0; d < 8; d++ {
+		dir := fmt.Sprintf("tool/lib%d/", d)
+		if err := tw.WriteHeader(&tar.Header{Name: dir, Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+			tb.Fatal(err)
+		}
+		for f := 0; f < 32; f++ {
+			header := &tar.Header{Name: fmt.Sprintf("%sfile%d", dir, f), Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))}
+			if err := tw.WriteHeader(header); err != nil {
+				tb.Fatal(err)
+			}
+			if _, err := tw.Write(data); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "tool/bin/tool", Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(data))}); err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		tb.Fatal(err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "tool/bin/alias", Typeflag: tar.TypeSymlink, Linkname: "tool"}); err != nil {
+		tb.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func benchmarkExtract(b *testing.B, archive string, extract func(string, string) error) {
+	b.SetBytes(int64(len(benchTarball(b))))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := extract(archive, b.TempDir()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExtractTarGz(b *testing.B) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	gzw.Write(benchTarball(b))
+	gzw.Close()
+
+	archive := filepath.Join(b.TempDir(), "bench.tar.gz")
+	if err := os.WriteFile(archive, buf.Bytes(), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	// Each path on its own, as extractTarGz picks pigz whenever it's there
+	b.Run("stdlib", func(b *testing.B) {
+		benchmarkExtract(b, archive, func(archive string, dir string) error {
+			file, err := os.Open(archive)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			gzr, err := gzip.NewReader(file)
+			if err != nil {
+				return err
+			}
+			defer gzr.Close()
+			return untar(gzr, dir)
+		})
+	})
+	b.Run("pigz", func(b *testing.B) {
+		if _, err := exec.LookPath("pigz"); err != nil {
+			b.Skip("pigz not installed")
+		}
+		benchmarkExtract(b, archive, func(archive string, dir string) error {
+			file, err := os.Open(archive)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			return untarThrough(file, dir, "pigz", "-dc")
+		})
+	})
+}
+
+func BenchmarkExtractTarZst(b *testing.B) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		b.Skip("zstd not installed")
+	}
+
+	dir := b.TempDir()
+	archive := filepath.Join(dir, "bench.tar.zst")
+	cmd := exec.Command("zstd", "-q", "-o", archive)
+	cmd.Stdin = bytes.NewReader(benchTarball(b))
+	if err := cmd.Run(); err != nil {
+		b.Fatal(err)
+	}
+	benchmarkExtract(b, archive, extractTarZst)
+}
+
+// TestUntarLayout checks the parts of the layout the external tools used to
+// handle differently: permissions and symlinks
+func TestUntarLayout(t *testing.T) {
+	dir := t.TempDir()
+	if err := untar(bytes.NewReader(benchTarball(t)), dir); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "tool/bin/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("tool/bin/tool lost its executable bit: %v", info.Mode())
+	}
+	link, err := os.Readlink(filepath.Join(dir, "tool/bin/alias"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "tool" {
+		t.Errorf("tool/bin/alias links to %q, want tool", link)
+	}
+}
+
+// fuzzTarball builds a small tarball from headers, giving regular files a
+// line of content
+func fuzzTarball(tb testing.TB, headers ...*tar.Header) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, header := range headers {
+		body := []byte("content\n")
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(body))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			tb.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			tw.Write(body)
+		}
+	}
+	tw.Close()
+	return buf.Bytes()
+}
+
+// FuzzUntar checks that no tarball, however malformed, writes outside the
+// directory it is unpacked into
+func FuzzUntar(f *testing.F) {
+	f.Add(fuzzTarball(f,
+		&tar.Header{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "bin/tool", Typeflag: tar.TypeReg, Mode: 0755},
+		&tar.Header{Name: "bin/alias", Typeflag: tar.TypeSymlink, Linkname: "tool"},
+		&tar.Header{Name: "bin/hard", Typeflag: tar.TypeLink, Linkname: "bin/tool"},
+	))
+	f.Add(fuzzTarball(f, &tar.Header{Name: "../escape", Typeflag: tar.TypeReg, Mode: 0644}))
+	f.Add(fuzzTarball(f, &tar.Header{Name: "/abs/file", Typeflag: tar.TypeReg, Mode: 0644}))
+	f.Add(fuzzTarball(f,
+		&tar.Header{Name: "out", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		&tar.Header{Name: "out/escape", Typeflag: tar.TypeReg, Mode: 0644},
+	))
+	f.Add(fuzzTarball(f,
+		&tar.Header{Name: "out", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		&tar.Header{Name: "out", Typeflag: tar.TypeDir, Mode: 0700},
+	))
+	f.Add(fuzzTarball(f, &tar.Header{Name: "hard", Typeflag: tar.TypeLink, Linkname: "../outside"}))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		root := t.TempDir()
+		if err := os.Chmod(root, 0700); err != nil {
+			t.Fatal(err)
+		}
+		outside := filepath.Join(root, "outside")
+		if err := os.WriteFile(outside, []byte("untouched"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		dest := filepath.Join(root, "dest")
+		if err := os.Mkdir(dest, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		untar(bytes.NewReader(data), dest)
+
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 {
+			t.Fatalf("untar wrote outside its directory: %v", entries)
+		}
+		info, err := os.Stat(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0700 {
+			t.Fatalf("untar changed the permissions of its parent to %v", info.Mode())
+		}
+		if content, err := os.ReadFile(outside); err != nil || string(content) != "untouched" {
+			t.Fatalf("untar changed a file outside its directory: %q, %v", content, err)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package tomlfile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// UnknownKey is a key present in the file that no struct field decoded,
+// usually a typo like `verions =`
+type UnknownKey struct {
+	Key  string
+	Line int
+}
+
+// In formats the key as a warning about the file at path
+func (k UnknownKey) In(path string) string {
+	if k.Line == 0 {
+		return fmt.Sprintf("%s: unknown key %s", path, k.Key)
+	}
+	return fmt.Sprintf("%s:%d: unknown key %s", path, k.Line, k.Key)
+}
+
+// Deprecation is a key that still works but is going away. Key is dotted,
+// like settings.output, with * standing for any one part such as a package
+// name. Instead says what to use in its place.
+type Deprecation struct {
+	Key     string
+	Instead string
+}
+
+// Decode decodes the TOML file at path into v and reports keys it did not
+// use, and a warning for each of the deprecated keys the file sets. Parse
+// failures include the file name and line with the offending column.
+func Decode(path string, v any, deprecated ...Deprecation) ([]UnknownKey, []string, error) {
+	md, err := toml.DecodeFile(path, v)
+	if err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, nil, fmt.Errorf("%s: %s", path, parseErr.ErrorWithPosition())
+		}
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var warnings []string
+	for _, key := range md.Keys() {
+		for _, d := range deprecated {
+			if !keyMatches(key, d.Key) {
+				continue
+			}
+			where := path
+			if line := findLine(path, key); line > 0 {
+				where = fmt.Sprintf("%s:%d", path, line)
+			}
+			warnings = append(warnings, fmt.Sprintf("%s: %s is deprecated and will be removed, use %s instead", where, key, d.Instead))
+		}
+	}
+
+	var unknown []UnknownKey
+	reported := make(map[string]bool)
+	for _, key := range md.Undecoded() {
+		// Report an unknown table once rather than every key inside it
+		if reported[key[:len(key)-1].String()] {
+			reported[key.String()] = true
+			continue
+		}
+		reported[key.String()] = true
+
+		unknown = append(unknown, UnknownKey{
+			Key:  key.String(),
+			Line: findLine(path, key),
+		})
+	}
+	return unknown, warnings, nil
+}
+
+// keyMatches reports whether key is the dotted pattern, where * matches any
+// one part
+func keyMatches(key toml.Key, pattern string) bool {
+	parts := strings.Split(pattern, ".")
+	if len(parts) != len(key) {
+		return false
+	}
+	for i, part := range parts {
+		if part != "*" && part != key[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckSchemaVersion fails for a file written for a newer format than
+// supported. A file without schema_version is version 1.
+func CheckSchemaVersion(version int, supported int) error {
+	switch {
+	case version < 0:
+		return fmt.Errorf("schema_version must be at least 1, got %d", version)
+	case version > supported:
+		return fmt.Errorf("schema_version %d is newer than this yourpm understands (up to %d), upgrade yourpm", version, supported)
+	}
+	return nil
+}
+
+// envName is what a shell accepts as a variable name
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CheckEnvNames fails for an env table with a key that isn't a valid
+// variable name, which the activation scripts would otherwise run as code.
+// section is the table's dotted key, for the error.
+func CheckEnvNames(section string, env map[string]string) error {
+	for key := range env {
+		if !envName.MatchString(key) {
+			return fmt.Errorf("%s: %q is not a valid environment variable name", section, key)
+		}
+	}
+	return nil
+}
+
+// findLine makes a best effort to locate the line a key was defined on,
+// since MetaData does not keep key positions
+func findLine(path string, key toml.Key) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	parent := strings.Join(key[:len(key)-1], ".")
+	assignment := regexp.MustCompile(`^\s*"?` + regexp.QuoteMeta(key[len(key)-1]) + `"?\s*=`)
+
+	table := ""
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if match := headerPattern.FindStringSubmatch(text); match != nil {
+			table = strings.ReplaceAll(match[1], " ", "")
+			if table == key.String() {
+				return line
+			}
+			continue
+		}
+		if table == parent && assignment.MatchString(text) {
+			return line
+		}
+	}
+	return 0
+}
+
+var headerPattern = regexp.MustCompile(`^\[+([^\]]+)\]+`)
@@ -0,0 +1,114 @@
+// Package undo records what the store and profile held before a command
+// that deletes from them, so yourpm undo can put it back
+package undo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Record is the store and profile as they were before an operation
+type Record struct {
+	Operation string    `toml:"operation"`
+	CreatedAt time.Time `toml:"created_at"`
+	Entries   []Entry   `toml:"entries"`
+
+	// Links maps each symlink in the profile's bin dir to its target
+	Links map[string]string `toml:"links"`
+}
+
+// Entry is a store entry and where it can be downloaded from again
+type Entry struct {
+	Name     string   `toml:"name"`
+	Version  string   `toml:"version"`
+	URL      string   `toml:"url"`
+	Binaries []string `toml:"binaries"`
+}
+
+// Links reads the symlinks in binDir. Shims and sandbox wrappers are left
+// out: they are files that survive in place and work again once the store
+// entry they run is back.
+func Links(binDir string) (map[string]string, error) {
+	files, err := os.ReadDir(binDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	links := make(map[string]string)
+	for _, file := range files {
+		if file.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(binDir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		links[file.Name()] = target
+	}
+	return links, nil
+}
+
+// RestoreLinks recreates the links missing from binDir and returns their
+// names. Anything in their place now, say from a later switch, is left be.
+func RestoreLinks(binDir string, links map[string]string) ([]string, error) {
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return nil, err
+	}
+
+	var restored []string
+	for name, target := range links {
+		path := filepath.Join(binDir, name)
+		if _, err := os.Lstat(path); err == nil {
+			continue
+		}
+		if err := os.Symlink(target, path); err != nil {
+			return restored, fmt.Errorf("failed to link %s: %w", name, err)
+		}
+		restored = append(restored, name)
+	}
+	sort.Strings(restored)
+	return restored, nil
+}
+
+// Save writes the record, replacing the previous one: only the latest
+// operation can be undone
+func Save(path string, record *Record) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := toml.NewEncoder(file).Encode(record); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads the record at path, or returns nil if there isn't one
+func Load(path string) (*Record, error) {
+	var record Record
+	if _, err := toml.DecodeFile(path, &record); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read undo record: %w", err)
+	}
+	return &record, nil
+}
@@ -0,0 +1,80 @@
+// Package version orders package version strings
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Compare returns -1, 0 or 1 as a is older than, the same as or newer than b.
+// Versions are split on dots, dashes and pluses; numeric parts compare as
+// numbers and anything else as text, so "1.10" is newer than "1.9". A
+// leading "v" is ignored.
+func Compare(a string, b string) int {
+	as, bs := split(a), split(b)
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		// A trailing number is a newer release, trailing text a pre-release
+		if i >= len(as) {
+			return -extra(bs[i])
+		}
+		if i >= len(bs) {
+			return extra(as[i])
+		}
+		if c := comparePart(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// Newest returns the newest of versions, or "" if there are none
+func Newest(versions []string) string {
+	newest := ""
+	for _, v := range versions {
+		if newest == "" || Compare(v, newest) > 0 {
+			newest = v
+		}
+	}
+	return newest
+}
+
+func split(v string) []string {
+	v = strings.TrimPrefix(v, "v")
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == '.' || r == '-' || r == '+'
+	})
+}
+
+// extra is how a version with a trailing part compares to one without it
+func extra(part string) int {
+	if _, err := strconv.Atoi(part); err == nil {
+		return 1
+	}
+	return -1
+}
+
+func comparePart(a string, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareInt(an, bn)
+	case aErr == nil:
+		return 1
+	case bErr == nil:
+		return -1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func compareInt(a int, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}